@@ -0,0 +1,268 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+	"github.com/Cedi-Search/Cedi-Search-Engine/targets"
+	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
+)
+
+// Server is the admin HTTP API for managing targets.
+//
+//	GET  /targets                          list every target
+//	POST /targets                          create a target
+//	POST /targets/validate                 validate a target without saving it
+//	GET  /targets/{name}                   get a target
+//	PUT  /targets/{name}                   update a target
+//	POST /targets/{name}/enable            enable a target
+//	POST /targets/{name}/disable           disable a target
+//	GET  /targets/{name}/history           list a target's versions
+//	GET  /targets/{name}/history/{version} get one version
+//	POST /targets/{name}/rollback/{version} make an earlier version current
+//
+// Every request needs an "Authorization: Bearer <token>" header. Changes are
+// attributed to the X-Admin-User header. Running crawlers pick up target
+// changes when they are restarted.
+type Server struct {
+	db    *database.Database
+	token string
+	mux   *http.ServeMux
+}
+
+// NewServer creates the admin API. The token must not be empty.
+func NewServer(db *database.Database, token string) (*Server, error) {
+	if token == "" {
+		return nil, errors.New("the admin API needs a token, set ADMIN_TOKEN")
+	}
+
+	server := &Server{db: db, token: token, mux: http.NewServeMux()}
+
+	server.mux.HandleFunc("GET /targets", server.listTargets)
+	server.mux.HandleFunc("POST /targets", server.createTarget)
+	server.mux.HandleFunc("POST /targets/validate", server.validateTarget)
+	server.mux.HandleFunc("GET /targets/{name}", server.getTarget)
+	server.mux.HandleFunc("PUT /targets/{name}", server.updateTarget)
+	server.mux.HandleFunc("POST /targets/{name}/enable", server.setDisabled(false))
+	server.mux.HandleFunc("POST /targets/{name}/disable", server.setDisabled(true))
+	server.mux.HandleFunc("GET /targets/{name}/history", server.targetHistory)
+	server.mux.HandleFunc("GET /targets/{name}/history/{version}", server.targetVersion)
+	server.mux.HandleFunc("POST /targets/{name}/rollback/{version}", server.rollbackTarget)
+
+	return server, nil
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	if !found || subtle.ConstantTimeCompare([]byte(token), []byte(server.token)) != 1 {
+		writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+
+	server.mux.ServeHTTP(w, r)
+}
+
+// change is the body of a create or update: the target plus a note for its history.
+type change struct {
+	data.Target
+	Comment string `json:"comment"`
+}
+
+func (server *Server) listTargets(w http.ResponseWriter, r *http.Request) {
+	all, err := server.db.GetAllTargets()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, all)
+}
+
+func (server *Server) getTarget(w http.ResponseWriter, r *http.Request) {
+	target, err := server.db.GetTarget(r.PathValue("name"))
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, target)
+}
+
+func (server *Server) validateTarget(w http.ResponseWriter, r *http.Request) {
+	body, ok := readChange(w, r)
+	if !ok {
+		return
+	}
+
+	errs := targets.Validate(body.Target)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"valid": len(errs) == 0, "errors": errs})
+}
+
+func (server *Server) createTarget(w http.ResponseWriter, r *http.Request) {
+	body, ok := readValidChange(w, r)
+	if !ok {
+		return
+	}
+
+	target, err := server.db.CreateTarget(body.Target, author(r), body.Comment)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	utils.Log(utils.Default).Info("Created target", "source", target.Target, "author", author(r))
+
+	writeJSON(w, http.StatusCreated, target)
+}
+
+func (server *Server) updateTarget(w http.ResponseWriter, r *http.Request) {
+	body, ok := readValidChange(w, r)
+	if !ok {
+		return
+	}
+
+	if body.Target.Target != r.PathValue("name") {
+		writeError(w, http.StatusBadRequest, errors.New("a target can't be renamed"))
+		return
+	}
+
+	target, err := server.db.UpdateTarget(body.Target, author(r), body.Comment)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	utils.Log(utils.Default).Info("Updated target", "source", target.Target, "version", target.Version, "author", author(r))
+
+	writeJSON(w, http.StatusOK, target)
+}
+
+func (server *Server) setDisabled(disabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target, err := server.db.SetTargetDisabled(r.PathValue("name"), disabled, author(r))
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		utils.Log(utils.Default).Info("Changed target", "source", target.Target, "disabled", disabled, "author", author(r))
+
+		writeJSON(w, http.StatusOK, target)
+	}
+}
+
+func (server *Server) targetHistory(w http.ResponseWriter, r *http.Request) {
+	versions, err := server.db.GetTargetHistory(r.PathValue("name"))
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, versions)
+}
+
+func (server *Server) targetVersion(w http.ResponseWriter, r *http.Request) {
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	saved, err := server.db.GetTargetVersion(r.PathValue("name"), version)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, saved)
+}
+
+func (server *Server) rollbackTarget(w http.ResponseWriter, r *http.Request) {
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	target, err := server.db.RollbackTarget(r.PathValue("name"), version, author(r))
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	utils.Log(utils.Default).Info("Rolled back target", "source", target.Target, "to", version, "version", target.Version, "author", author(r))
+
+	writeJSON(w, http.StatusOK, target)
+}
+
+// readChange decodes a create or update body, answering 400 when it can't.
+func readChange(w http.ResponseWriter, r *http.Request) (change, bool) {
+	body := change{}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(&body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return body, false
+	}
+
+	return body, true
+}
+
+// readValidChange decodes a create or update body and validates its target,
+// answering 422 with every problem when it isn't valid.
+func readValidChange(w http.ResponseWriter, r *http.Request) (change, bool) {
+	body, ok := readChange(w, r)
+	if !ok {
+		return body, false
+	}
+
+	if errs := targets.Validate(body.Target); len(errs) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{"valid": false, "errors": errs})
+		return body, false
+	}
+
+	return body, true
+}
+
+func author(r *http.Request) string {
+	if user := r.Header.Get("X-Admin-User"); user != "" {
+		return user
+	}
+
+	return "admin"
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(value)
+	utils.HandleErr(err, utils.Default, "Failed to write admin API response")
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeDBError answers with the status matching a database error.
+func writeDBError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, database.ErrTargetNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, database.ErrTargetExists), errors.Is(err, database.ErrTargetChanged):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
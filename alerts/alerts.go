@@ -0,0 +1,144 @@
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Evaluator checks recorded prices against subscriptions and
+// delivers alerts through the notifier of each subscription's channel.
+type Evaluator struct {
+	db        *database.Database
+	notifiers map[string]Notifier
+}
+
+// NewEvaluator creates an Evaluator with no notifiers.
+// Register them with Register before adding it as a price observer.
+func NewEvaluator(db *database.Database) *Evaluator {
+	return &Evaluator{
+		db:        db,
+		notifiers: map[string]Notifier{},
+	}
+}
+
+// Register makes `notifier` deliver alerts for subscriptions on `channel`, e.g. "webhook".
+func (evaluator *Evaluator) Register(channel string, notifier Notifier) {
+	evaluator.notifiers[channel] = notifier
+}
+
+// ObservePrice implements database.PriceObserver.
+//
+// A subscriber is alerted when the price is at or below their threshold, and
+// again only if it drops further. Once the price goes back above the threshold,
+// the next drop alerts again.
+func (evaluator *Evaluator) ObservePrice(product map[string]interface{}, point data.PricePoint) {
+	err := evaluator.db.RearmAlerts(point.ProductID, point.Price)
+	utils.HandleErr(err, utils.Alerts, "Failed to rearm alerts", "product", point.ProductID)
+
+	subs, err := evaluator.db.FindTriggeredSubscriptions(point.ProductID, point.Price)
+	if utils.HandleErr(err, utils.Alerts, "Failed to find subscriptions", "product", point.ProductID) {
+		return
+	}
+
+	name, _ := product["name"].(string)
+	url, _ := product["url"].(string)
+
+	for _, sub := range subs {
+		if sub.ProductID == "" && !matchesQuery(sub, name, point.Source) {
+			continue
+		}
+
+		previous, err := evaluator.db.GetAlertDelivery(sub.ID, point.ProductID)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			utils.HandleErr(err, utils.Alerts, "Failed to get alert delivery", "subscription", sub.ID)
+			continue
+		}
+
+		delivered := err == nil
+
+		// Claimed before sending, so concurrent recrawls of the product alert only once.
+		claimed, err := evaluator.db.ClaimAlertDelivery(data.AlertDelivery{
+			SubscriptionID: sub.ID,
+			ProductID:      point.ProductID,
+			Threshold:      sub.Threshold,
+			Price:          point.Price,
+			DeliveredAt:    point.ObservedAt,
+		})
+		if utils.HandleErr(err, utils.Alerts, "Failed to claim alert delivery", "subscription", sub.ID) || !claimed {
+			continue
+		}
+
+		alert := data.Alert{
+			SubscriptionID: sub.ID,
+			ProductID:      point.ProductID,
+			Name:           strings.TrimSpace(name),
+			URL:            url,
+			Source:         point.Source,
+			Price:          point.Price,
+			Threshold:      sub.Threshold,
+			ObservedAt:     point.ObservedAt,
+		}
+
+		err = evaluator.deliver(sub, alert)
+		if err == nil {
+			continue
+		}
+
+		utils.HandleErr(err, utils.Alerts, "Failed to deliver alert", "subscription", sub.ID)
+
+		// Give the claim back so the next recrawl tries again.
+		if delivered {
+			err = evaluator.db.SaveAlertDelivery(previous)
+		} else {
+			err = evaluator.db.DeleteAlertDelivery(sub.ID, point.ProductID)
+		}
+
+		utils.HandleErr(err, utils.Alerts, "Failed to release alert delivery", "subscription", sub.ID)
+	}
+}
+
+// deliver sends an alert through the notifier registered for the subscription's channel.
+func (evaluator *Evaluator) deliver(sub data.Subscription, alert data.Alert) error {
+	channel, recipient, found := strings.Cut(sub.Notify, ":")
+	if !found {
+		return fmt.Errorf("malformed notify %q", sub.Notify)
+	}
+
+	notifier, found := evaluator.notifiers[channel]
+	if !found {
+		return fmt.Errorf("no notifier registered for %q", channel)
+	}
+
+	utils.Log(utils.Alerts).Info("Alerting", "notify", sub.Notify, "product", alert.ProductID)
+
+	return notifier.Notify(recipient, alert)
+}
+
+// matchesQuery reports whether a product name contains every word of a
+// subscription's saved query, ignoring case, and comes from its source if one is set.
+func matchesQuery(sub data.Subscription, name, source string) bool {
+	if sub.Source != "" && !strings.EqualFold(sub.Source, source) {
+		return false
+	}
+
+	name = strings.ToLower(name)
+
+	terms := strings.Fields(strings.ToLower(sub.Query))
+	if len(terms) == 0 {
+		return false
+	}
+
+	for _, term := range terms {
+		if !strings.Contains(name, term) {
+			return false
+		}
+	}
+
+	return true
+}
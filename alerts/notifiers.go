@@ -0,0 +1,124 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/config"
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+)
+
+// Notifier delivers an alert to a recipient over a single channel.
+type Notifier interface {
+	Notify(recipient string, alert data.Alert) error
+}
+
+// WebhookNotifier POSTs alerts as JSON to the recipient URL.
+type WebhookNotifier struct {
+	client *http.Client
+}
+
+func NewWebhookNotifier() *WebhookNotifier {
+	return &WebhookNotifier{
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (notifier *WebhookNotifier) Notify(recipient string, alert data.Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, recipient, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", config.USER_AGENT)
+
+	res, err := notifier.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s responded with %s", recipient, res.Status)
+	}
+
+	return nil
+}
+
+// SMTPNotifier emails alerts to the recipient address.
+type SMTPNotifier struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTPNotifier(addr, from string, auth smtp.Auth) *SMTPNotifier {
+	return &SMTPNotifier{
+		addr: addr,
+		from: from,
+		auth: auth,
+	}
+}
+
+// NewSMTPNotifierFromEnv configures an SMTPNotifier from SMTP_ADDR, SMTP_FROM,
+// SMTP_USERNAME and SMTP_PASSWORD. Auth is skipped when no username is set,
+// which is what local stand-ins like MailHog expect.
+//
+// Returns nil if SMTP_ADDR is not set.
+func NewSMTPNotifierFromEnv() *SMTPNotifier {
+	addr := os.Getenv("SMTP_ADDR")
+	if addr == "" {
+		return nil
+	}
+
+	var auth smtp.Auth
+
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		host := strings.Split(addr, ":")[0]
+		auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	return NewSMTPNotifier(addr, os.Getenv("SMTP_FROM"), auth)
+}
+
+func (notifier *SMTPNotifier) Notify(recipient string, alert data.Alert) error {
+	// Product names are scraped, so they mustn't be able to end the header.
+	name := strings.NewReplacer("\r", " ", "\n", " ").Replace(alert.Name)
+
+	subject := fmt.Sprintf("Price drop: %s is now GH₵ %.2f", name, alert.Price)
+
+	body := fmt.Sprintf(
+		"%s on %s is now GH₵ %.2f, at or below your alert price of GH₵ %.2f.\r\n\r\n%s\r\n",
+		alert.Name,
+		alert.Source,
+		alert.Price,
+		alert.Threshold,
+		alert.URL,
+	)
+
+	msg := strings.Join([]string{
+		"From: " + notifier.from,
+		"To: " + recipient,
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(notifier.addr, notifier.auth, notifier.from, []string{recipient}, []byte(msg))
+}
@@ -0,0 +1,194 @@
+package alerts
+
+import (
+	"bufio"
+	"encoding/json"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/config"
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	received := make(chan data.Alert, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", got)
+		}
+
+		if got := r.Header.Get("User-Agent"); got != config.USER_AGENT {
+			t.Errorf("User-Agent = %q, want %q", got, config.USER_AGENT)
+		}
+
+		alert := data.Alert{}
+
+		err := json.NewDecoder(r.Body).Decode(&alert)
+		if err != nil {
+			t.Errorf("decoding alert: %v", err)
+		}
+
+		received <- alert
+	}))
+	defer server.Close()
+
+	alert := data.Alert{
+		SubscriptionID: "sub-1",
+		ProductID:      "https://www.jumia.com.gh/tecno-spark-10-pro-48823011.html",
+		Name:           "Tecno Spark 10 Pro",
+		URL:            "https://www.jumia.com.gh/tecno-spark-10-pro-48823011.html",
+		Source:         "Jumia",
+		Price:          2199,
+		Threshold:      2300,
+		ObservedAt:     time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	err := NewWebhookNotifier().Notify(server.URL, alert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := <-received; got != alert {
+		t.Errorf("webhook received %+v, want %+v", got, alert)
+	}
+}
+
+func TestWebhookNotifierRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := NewWebhookNotifier().Notify(server.URL, data.Alert{})
+	if err == nil {
+		t.Fatal("expected an error for a 502 response")
+	}
+}
+
+// smtpServer accepts a single message over SMTP on a local port, standing in
+// for a mail server, and sends what it received on the returned channel.
+func smtpServer(t *testing.T) (string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 localhost ready")
+
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			command := strings.ToUpper(strings.TrimSpace(line))
+
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case command == "DATA":
+				reply("354 go ahead")
+
+				msg := strings.Builder{}
+
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+
+					if line == ".\r\n" {
+						break
+					}
+
+					msg.WriteString(line)
+				}
+
+				received <- msg.String()
+
+				reply("250 queued")
+			case command == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), received
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, received := smtpServer(t)
+
+	alert := data.Alert{
+		Name:      "Tecno Spark 10 Pro\r\nBcc: everyone@example.com",
+		URL:       "https://www.jumia.com.gh/tecno-spark-10-pro-48823011.html",
+		Source:    "Jumia",
+		Price:     2199,
+		Threshold: 2300,
+	}
+
+	err := NewSMTPNotifier(addr, "alerts@cedisearch.com", nil).Notify("shopper@example.com", alert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(<-received))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bcc := msg.Header.Get("Bcc"); bcc != "" {
+		t.Errorf("the product name injected a Bcc header: %q", bcc)
+	}
+
+	if to := msg.Header.Get("To"); to != "shopper@example.com" {
+		t.Errorf("To = %q, want shopper@example.com", to)
+	}
+
+	raw := msg.Header.Get("Subject")
+
+	for _, r := range raw {
+		if r > 127 {
+			t.Fatalf("Subject isn't ASCII: %q", raw)
+		}
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Price drop: Tecno Spark 10 Pro  Bcc: everyone@example.com is now GH₵ 2199.00"
+	if subject != want {
+		t.Errorf("Subject = %q, want %q", subject, want)
+	}
+}
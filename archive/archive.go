@@ -0,0 +1,287 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// Extension fields we add to response records on top of the WARC 1.1 standard ones.
+	sourceField  = "WARC-Cedi-Source"
+	fetcherField = "WARC-Cedi-Fetcher"
+)
+
+// Ref points to a single record inside a WARC file.
+type Ref struct {
+	File   string
+	Offset int64
+}
+
+// Archive writes fetched responses to gzip compressed WARC files,
+// one file per source per day under its directory, e.g. Jumia/2024-06-01.warc.gz
+// in ARCHIVE_DIR, or ./warc by default.
+//
+// Each record is its own gzip member so a record can be read back
+// by seeking straight to its offset.
+type Archive struct {
+	dir string
+	mu  sync.Mutex
+}
+
+var defaultArchive *Archive
+var defaultOnce sync.Once
+
+// Default returns the archive rooted at the ARCHIVE_DIR environment variable,
+// falling back to ./warc.
+func Default() *Archive {
+	defaultOnce.Do(func() {
+		dir := os.Getenv("ARCHIVE_DIR")
+		if dir == "" {
+			dir = "warc"
+		}
+
+		defaultArchive = NewArchive(dir)
+	})
+
+	return defaultArchive
+}
+
+func NewArchive(dir string) *Archive {
+	return &Archive{
+		dir: dir,
+	}
+}
+
+// Store appends a response record for `resp` to the source's archive file for today.
+//
+// It returns a reference to the record that can later be passed to Load.
+func (archive *Archive) Store(source string, resp utils.Response) (Ref, error) {
+	record, err := encodeRecord(source, resp)
+	if err != nil {
+		return Ref{}, err
+	}
+
+	archive.mu.Lock()
+	defer archive.mu.Unlock()
+
+	dir := filepath.Join(archive.dir, source)
+
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return Ref{}, err
+	}
+
+	file := filepath.Join(dir, resp.FetchedAt.UTC().Format("2006-01-02")+".warc.gz")
+
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return Ref{}, err
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return Ref{}, err
+	}
+
+	_, err = f.Write(record)
+	if err != nil {
+		return Ref{}, err
+	}
+
+	utils.Log(utils.Crawler).Debug("Archived", "source", source, "url", resp.URL, "file", file)
+
+	return Ref{File: file, Offset: info.Size()}, nil
+}
+
+// encodeRecord builds a single gzip compressed WARC response record.
+func encodeRecord(source string, resp utils.Response) ([]byte, error) {
+	var block bytes.Buffer
+
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	fmt.Fprintf(&block, "HTTP/1.1 %d %s\r\n", status, http.StatusText(status))
+
+	keys := make([]string, 0, len(resp.Header))
+	for key := range resp.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range resp.Header[key] {
+			fmt.Fprintf(&block, "%s: %s\r\n", key, value)
+		}
+	}
+
+	block.WriteString("\r\n")
+	block.WriteString(resp.Body)
+
+	digest := sha1.Sum(block.Bytes())
+
+	var record bytes.Buffer
+
+	record.WriteString("WARC/1.1\r\n")
+	fmt.Fprintf(&record, "WARC-Type: response\r\n")
+	fmt.Fprintf(&record, "WARC-Record-ID: <urn:uuid:%s>\r\n", uuid.New().String())
+	fmt.Fprintf(&record, "WARC-Date: %s\r\n", resp.FetchedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&record, "WARC-Target-URI: %s\r\n", resp.URL)
+	fmt.Fprintf(&record, "WARC-Block-Digest: sha1:%s\r\n", base32.StdEncoding.EncodeToString(digest[:]))
+	fmt.Fprintf(&record, "%s: %s\r\n", sourceField, source)
+	fmt.Fprintf(&record, "%s: %s\r\n", fetcherField, resp.Fetcher)
+	fmt.Fprintf(&record, "Content-Type: application/http;msgtype=response\r\n")
+	fmt.Fprintf(&record, "Content-Length: %d\r\n", block.Len())
+	record.WriteString("\r\n")
+	record.Write(block.Bytes())
+	record.WriteString("\r\n\r\n")
+
+	var compressed bytes.Buffer
+
+	gz := gzip.NewWriter(&compressed)
+
+	_, err := gz.Write(record.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	err = gz.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return compressed.Bytes(), nil
+}
+
+// Load reads back the response record `ref` points to.
+//
+// It returns the source the record was stored under and the response.
+func Load(ref Ref) (string, utils.Response, error) {
+	f, err := os.Open(ref.File)
+	if err != nil {
+		return "", utils.Response{}, err
+	}
+
+	defer f.Close()
+
+	_, err = f.Seek(ref.Offset, io.SeekStart)
+	if err != nil {
+		return "", utils.Response{}, err
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return "", utils.Response{}, err
+	}
+
+	gz.Multistream(false)
+
+	return decodeRecord(bufio.NewReader(gz))
+}
+
+// decodeRecord parses a single WARC response record.
+func decodeRecord(r *bufio.Reader) (string, utils.Response, error) {
+	resp := utils.Response{Header: http.Header{}}
+
+	version, err := r.ReadString('\n')
+	if err != nil {
+		return "", resp, err
+	}
+
+	if !strings.HasPrefix(version, "WARC/") {
+		return "", resp, fmt.Errorf("not a WARC record: %q", strings.TrimSpace(version))
+	}
+
+	fields, err := readFields(r)
+	if err != nil {
+		return "", resp, err
+	}
+
+	resp.URL = fields.Get("WARC-Target-URI")
+	resp.Fetcher = fields.Get(fetcherField)
+
+	resp.FetchedAt, err = time.Parse(time.RFC3339, fields.Get("WARC-Date"))
+	if err != nil {
+		return "", resp, err
+	}
+
+	length, err := strconv.ParseInt(fields.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return "", resp, err
+	}
+
+	block := bufio.NewReader(io.LimitReader(r, length))
+
+	statusLine, err := block.ReadString('\n')
+	if err != nil {
+		return "", resp, err
+	}
+
+	statusParts := strings.SplitN(strings.TrimSpace(statusLine), " ", 3)
+	if len(statusParts) < 2 {
+		return "", resp, fmt.Errorf("malformed status line: %q", statusLine)
+	}
+
+	resp.Status, err = strconv.Atoi(statusParts[1])
+	if err != nil {
+		return "", resp, err
+	}
+
+	resp.Header, err = readFields(block)
+	if err != nil {
+		return "", resp, err
+	}
+
+	body, err := io.ReadAll(block)
+	if err != nil {
+		return "", resp, err
+	}
+
+	resp.Body = string(body)
+
+	return fields.Get(sourceField), resp, nil
+}
+
+// readFields reads "Name: value" lines up to the first empty line.
+func readFields(r *bufio.Reader) (http.Header, error) {
+	fields := http.Header{}
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return fields, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			return fields, nil
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+
+		fields.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+}
@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+)
+
+// Blocked prints the sources that served us block pages: why, where,
+// how many times in a row and until when they are left alone.
+func Blocked(db *database.Database, args []string) error {
+	states, err := db.GetBlockStates()
+	if err != nil {
+		return err
+	}
+
+	if len(states) == 0 {
+		fmt.Println("No source is blocking us")
+		return nil
+	}
+
+	for _, state := range states {
+		status := "blocked"
+		if time.Now().After(state.BlockedUntil) {
+			status = "retrying"
+		}
+
+		fmt.Printf("%s: %s since %s, %d blocks in a row, cooling down until %s\n", state.Source, status, state.Since.Format(time.RFC3339), state.Blocks, state.BlockedUntil.Format(time.RFC3339))
+		fmt.Printf("  %s on %s\n", state.Reason, state.URL)
+	}
+
+	return nil
+}
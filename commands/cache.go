@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+	"github.com/Cedi-Search/Cedi-Search-Engine/targets"
+	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
+)
+
+// Cache manages the development response cache.
+//
+// Usage: cache purge [--source Jumia | --host www.jumia.com.gh | --all]
+func Cache(db *database.Database, args []string) error {
+	if len(args) == 0 || args[0] != "purge" {
+		return errors.New("usage: cache purge [--source Jumia | --host www.jumia.com.gh | --all]")
+	}
+
+	flags := flag.NewFlagSet("cache purge", flag.ContinueOnError)
+
+	source := flags.String("source", "", "purge the cached pages of this source, e.g. Jumia")
+	host := flags.String("host", "", "purge the cached pages of this host")
+	all := flags.Bool("all", false, "purge every cached page")
+
+	err := flags.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+
+	cache := utils.GetResponseCache()
+	if cache == nil {
+		return errors.New("the response cache is off, set FETCH_CACHE_DIR")
+	}
+
+	if *all {
+		fmt.Println("Purging", cache.Dir)
+		return cache.Purge()
+	}
+
+	if *source != "" {
+		loaded, err := targets.Load(db)
+		if err != nil {
+			return err
+		}
+
+		target, found := findTarget(loaded, *source)
+		if !found {
+			return fmt.Errorf("no target named %s", *source)
+		}
+
+		*host = target.Host
+	}
+
+	if *host == "" {
+		return errors.New("cache purge needs --source, --host or --all")
+	}
+
+	fmt.Println("Purging cached pages of", *host)
+
+	return cache.PurgeHost(*host)
+}
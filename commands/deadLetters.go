@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+)
+
+// DeadLetters lists, inspects and requeues the URLs the crawler gave up on.
+//
+// Usage:
+//
+//	dead-letters list [--source Jumia] [--limit 50]
+//	dead-letters show --url <url>
+//	dead-letters requeue (--url <url> | --source Jumia | --all)
+func DeadLetters(db *database.Database, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: dead-letters (list | show | requeue) [flags]")
+	}
+
+	flags := flag.NewFlagSet("dead-letters "+args[0], flag.ContinueOnError)
+
+	source := flags.String("source", "", "only dead letters of this source, e.g. Jumia")
+	href := flags.String("url", "", "the dead letter's URL")
+	limit := flags.Int64("limit", 50, "how many dead letters to list")
+	all := flags.Bool("all", false, "requeue every dead letter")
+
+	err := flags.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		letters, err := db.GetDeadLetters(*source, *limit)
+		if err != nil {
+			return err
+		}
+
+		for _, letter := range letters {
+			fmt.Printf("%s  %-8s %d attempts  %s\n    %s\n", letter.DiedAt.Format(time.RFC3339), letter.Source, letter.Attempts, letter.URL, letter.LastError)
+		}
+
+		fmt.Printf("%d dead letters\n", len(letters))
+
+	case "show":
+		if *href == "" {
+			return errors.New("dead-letters show needs --url")
+		}
+
+		letter, err := db.GetDeadLetter(*href)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("url:       ", letter.URL)
+		fmt.Println("source:    ", letter.Source)
+		fmt.Println("recrawl:   ", letter.Recrawl)
+		fmt.Println("attempts:  ", letter.Attempts)
+		fmt.Println("died at:   ", letter.DiedAt.Format(time.RFC3339))
+		fmt.Println("last error:", letter.LastError)
+		fmt.Println("trace:     ", letter.TraceParent)
+
+	case "requeue":
+		if *href != "" {
+			letter, err := db.GetDeadLetter(*href)
+			if err != nil {
+				return err
+			}
+
+			return db.RequeueDeadLetter(letter)
+		}
+
+		if *source == "" && !*all {
+			return errors.New("dead-letters requeue needs --url, --source or --all")
+		}
+
+		// Requeue everything, not just the latest page of dead letters.
+		letters, err := db.GetDeadLetters(*source, 0)
+		if err != nil {
+			return err
+		}
+
+		for _, letter := range letters {
+			err = db.RequeueDeadLetter(letter)
+			if err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("Requeued %d dead letters\n", len(letters))
+
+	default:
+		return fmt.Errorf("unknown dead-letters command %q", args[0])
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+)
+
+// Deals prints the products with the biggest discounts.
+//
+// Usage: deals [--source Jumia] [--min 20] [--limit 20]
+func Deals(db *database.Database, args []string) error {
+	flags := flag.NewFlagSet("deals", flag.ContinueOnError)
+
+	source := flags.String("source", "", "only show products of this source, e.g. Jumia")
+	minDiscount := flags.Float64("min", 0, "smallest discount percentage to show")
+	limit := flags.Int64("limit", 20, "number of products to show")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	products, err := db.GetTopDiscounts(*source, *minDiscount, *limit)
+	if err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		fmt.Printf("-%4.1f%%  %10.2f (was %.2f)  %-8s %s\n", product.DiscountPercent, product.Price, product.OldPrice, product.Source, strings.TrimSpace(product.Name))
+
+		if len(product.Promotions) > 0 {
+			fmt.Printf("         %s\n", strings.Join(product.Promotions, ", "))
+		}
+	}
+
+	return nil
+}
+
+// ConfigureSearch applies the search index settings, including the biggest discount replica.
+func ConfigureSearch(db *database.Database, args []string) error {
+	return db.ConfigureSearchIndex()
+}
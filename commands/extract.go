@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/crawler"
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+	"github.com/Cedi-Search/Cedi-Search-Engine/indexer"
+	"github.com/Cedi-Search/Cedi-Search-Engine/targets"
+	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
+)
+
+// Extract runs a target's selectors against a live or saved page and prints,
+// field by field, what they matched and what came out of it. Nothing is saved
+// to the database. With --save, a fetched page is written to a file as a golden
+// test fixture, starting with a comment holding its URL.
+//
+// Usage: extract --target Jumia (--url https://... | --file page.html) [--fetcher rod|soup] [--save page.html]
+func Extract(db *database.Database, args []string) error {
+	flags := flag.NewFlagSet("extract", flag.ContinueOnError)
+
+	name := flags.String("target", "", "target whose selectors to run, e.g. Jumia")
+	href := flags.String("url", "", "product page to fetch")
+	file := flags.String("file", "", "saved product page to read instead of fetching one")
+	fetcher := flags.String("fetcher", "", "fetcher to use instead of the target's, rod or soup")
+	save := flags.String("save", "", "file to save the fetched page to, as a golden test fixture")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *name == "" || (*href == "") == (*file == "") {
+		return errors.New("usage: extract --target Jumia (--url https://... | --file page.html)")
+	}
+
+	if *save != "" && *href == "" {
+		return errors.New("--save needs --url")
+	}
+
+	all, err := targets.Load(db)
+	if err != nil {
+		return err
+	}
+
+	target, found := findTarget(all, *name)
+	if !found {
+		return fmt.Errorf("no target named %s", *name)
+	}
+
+	page := data.CrawledPage{
+		URL:     *href,
+		Source:  target.Target,
+		Attribs: target.Data,
+	}
+
+	if *file != "" {
+		content, err := os.ReadFile(*file)
+		if err != nil {
+			return err
+		}
+
+		page.URL = "file://" + *file
+		page.HTML = string(content)
+	} else {
+		if *fetcher == "" {
+			*fetcher = crawler.Fetcher(target.Target)
+		}
+
+		// Fetch through the target's proxies like the crawler does, so pages
+		// that are only blocked without them don't look broken here.
+		for _, err := range utils.AssignProxies(target.Host, target.Proxies) {
+			fmt.Println("Warning: invalid proxy:", err)
+		}
+
+		start := time.Now()
+
+		resp, err := utils.Fetch(*href, *fetcher)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Fetched %s with %s: %d, %d bytes in %s\n", resp.FinalURL, resp.Fetcher, resp.Status, len(resp.Body), time.Since(start).Round(time.Millisecond))
+
+		if reason := utils.DetectBlock(resp); reason != "" {
+			fmt.Println("Warning: this looks like a block page:", reason)
+		}
+
+		if *save != "" {
+			err = os.WriteFile(*save, []byte(fmt.Sprintf("<!-- %s -->\n%s", *href, resp.Body)), 0o644)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("Saved to", *save)
+		}
+
+		fmt.Println()
+
+		page.HTML = resp.Body
+	}
+
+	product, fields := indexer.NewIndexer(db).ExtractFields(page)
+
+	failed := 0
+
+	for _, field := range fields {
+		fmt.Println(field.Label)
+
+		if len(field.Elements) == 0 {
+			fmt.Println("  element: none")
+		}
+
+		for i, element := range field.Elements {
+			fmt.Println("  element:", truncate(element, 120))
+			fmt.Printf("  raw:     %q\n", truncate(strings.TrimSpace(field.Raw[i]), 120))
+		}
+
+		fmt.Printf("  value:   %v\n", field.Value)
+
+		if field.Error != "" {
+			fmt.Println("  error:  ", field.Error)
+			failed++
+		}
+
+		fmt.Println()
+	}
+
+	fmt.Printf("availability: %v\n", product["availability"])
+	fmt.Printf("%d of %d fields extracted\n", len(fields)-failed, len(fields))
+
+	return nil
+}
+
+// truncate shortens a text to at most n runes, marking the cut with an ellipsis.
+func truncate(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+
+	return string(runes[:n-1]) + "…"
+}
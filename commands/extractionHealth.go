@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+)
+
+// ExtractionHealth prints, per source and field, how often the field was
+// extracted lately against its baseline, and the pages of broken fields.
+//
+// Usage: extraction-health [--source Jumia]
+func ExtractionHealth(db *database.Database, args []string) error {
+	flags := flag.NewFlagSet("extraction-health", flag.ContinueOnError)
+	source := flags.String("source", "", "only show the fields of this source, e.g. Jumia")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	fields, err := db.GetFieldHealth(*source)
+	if err != nil {
+		return err
+	}
+
+	if len(fields) == 0 {
+		fmt.Println("No extraction health checks yet")
+		return nil
+	}
+
+	fmt.Printf("%-12s %-20s %9s %9s %6s  %s\n", "source", "field", "fill", "baseline", "pages", "checked")
+
+	for _, field := range fields {
+		status := ""
+		if field.Alerting {
+			status = "  BROKEN"
+		}
+
+		fmt.Printf("%-12s %-20s %8.1f%% %8.1f%% %6d  %s%s\n", field.Source, field.Field, field.FillRate*100, field.Baseline*100, field.Pages, field.CheckedAt.Format(time.RFC3339), status)
+
+		if field.Alerting {
+			for _, url := range field.FailingURLs {
+				fmt.Println("  failed on", url)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+	"github.com/Cedi-Search/Cedi-Search-Engine/matcher"
+)
+
+// Match groups the same product sold by different shops.
+//
+// Usage: match [--hash-images] [--show]
+func Match(db *database.Database, args []string) error {
+	flags := flag.NewFlagSet("match", flag.ContinueOnError)
+
+	hashImages := flags.Bool("hash-images", false, "download and hash product images that have no hash yet")
+	show := flags.Bool("show", false, "print every group sold by more than one store")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	groups, err := matcher.Match(db, *hashImages)
+	if err != nil {
+		return err
+	}
+
+	if *show {
+		for _, group := range groups {
+			if group.Stores < 2 {
+				continue
+			}
+
+			fmt.Printf("%s — %s\n", group.Name, group.Summary)
+
+			for _, offer := range group.Offers {
+				fmt.Printf("  %-8s %10.2f  %s\n", offer.Source, offer.Price, offer.URL)
+			}
+		}
+	}
+
+	return nil
+}
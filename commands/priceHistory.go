@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+)
+
+// PriceHistory prints the price timeline of a product.
+//
+// Usage: price-history --url <product url>
+func PriceHistory(db *database.Database, args []string) error {
+	flags := flag.NewFlagSet("price-history", flag.ContinueOnError)
+
+	url := flags.String("url", "", "URL of the product")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *url == "" {
+		return errors.New("price-history needs --url")
+	}
+
+	timeline, err := db.GetPriceTimeline(*url)
+	if err != nil {
+		return err
+	}
+
+	if len(timeline.Points) == 0 {
+		fmt.Println("No prices recorded for", timeline.ProductID)
+		return nil
+	}
+
+	fmt.Println(timeline.ProductID)
+
+	for _, point := range timeline.Points {
+		fmt.Printf("  %s  %10.2f  %s\n", point.ObservedAt.Format(time.DateTime), point.Price, point.Source)
+	}
+
+	fmt.Printf("all-time low %.2f, all-time high %.2f, 30-day average %.2f, change %+.1f%%\n",
+		timeline.Stats.AllTimeLow,
+		timeline.Stats.AllTimeHigh,
+		timeline.Stats.Average30d,
+		timeline.Stats.PercentChange,
+	)
+
+	return nil
+}
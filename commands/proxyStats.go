@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+)
+
+// ProxyStats prints, per proxy, how many fetches went through it and
+// how many of them succeeded, failed or got blocked.
+func ProxyStats(db *database.Database, args []string) error {
+	stats, err := db.GetProxyStats()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-40s %10s %8s %10s %8s\n", "proxy", "requests", "success", "failures", "blocks")
+
+	for _, stat := range stats {
+		rate := 0.0
+		if stat.Requests > 0 {
+			rate = float64(stat.Successes) / float64(stat.Requests) * 100
+		}
+
+		fmt.Printf("%-40s %10d %7.1f%% %10d %8d\n", stat.Proxy, stat.Requests, rate, stat.Failures, stat.Blocks)
+	}
+
+	return nil
+}
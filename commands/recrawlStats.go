@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+)
+
+// RecrawlStats prints, per source, how many conditional recrawls the shop
+// answered with 304 Not Modified and roughly how much downloading that saved.
+func RecrawlStats(db *database.Database, args []string) error {
+	stats, err := db.GetRevalidationStats()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-10s %10s %10s %8s %12s\n", "source", "requests", "304s", "ratio", "saved")
+
+	for _, stat := range stats {
+		ratio := 0.0
+		if stat.Requests > 0 {
+			ratio = float64(stat.NotModified) / float64(stat.Requests) * 100
+		}
+
+		fmt.Printf("%-10s %10d %10d %7.1f%% %9.1f MB\n", stat.Source, stat.Requests, stat.NotModified, ratio, float64(stat.BytesSaved)/1024/1024)
+	}
+
+	return nil
+}
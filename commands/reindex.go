@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/archive"
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+	"github.com/Cedi-Search/Cedi-Search-Engine/indexer"
+	"github.com/Cedi-Search/Cedi-Search-Engine/targets"
+	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Reindex replays archived pages through the indexer using the current
+// target definitions and updates the matching products in place.
+//
+// Usage: reindex [--source Jumia] [--url-pattern regex] [--dry-run]
+func Reindex(db *database.Database, args []string) error {
+	flags := flag.NewFlagSet("reindex", flag.ContinueOnError)
+
+	source := flags.String("source", "", "only replay pages of this source, e.g. Jumia")
+	urlPattern := flags.String("url-pattern", "", "only replay pages whose URL matches this regular expression")
+	dryRun := flags.Bool("dry-run", false, "print a field diff instead of updating products")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *source == "" && *urlPattern == "" {
+		return errors.New("reindex needs --source and/or --url-pattern")
+	}
+
+	all, err := targets.Load(db)
+	if err != nil {
+		return err
+	}
+
+	pages, err := db.FindCrawledPages(*source, *urlPattern)
+	if err != nil {
+		return err
+	}
+
+	utils.Log(utils.Indexer).Info("Reindexing", "pages", len(pages), "source", *source, "url_pattern", *urlPattern)
+
+	idx := indexer.NewIndexer(db)
+
+	updated, unchanged, failed := 0, 0, 0
+
+	for _, page := range pages {
+		target, found := findTarget(all, page.Source)
+		if !found {
+			utils.Log(utils.Indexer).Error("No target, skipping page", "source", page.Source, "url", page.URL)
+			failed++
+			continue
+		}
+
+		_, resp, err := archive.Load(archive.Ref{File: page.WarcFile, Offset: page.WarcOffset})
+		if utils.HandleErr(err, utils.Indexer, "Failed to load archived page", "url", page.URL) {
+			failed++
+			continue
+		}
+
+		page.HTML = resp.Body
+		page.Attribs = target.Data
+
+		product := idx.Extract(page)
+
+		if *dryRun {
+			existing, err := db.GetProduct(page.URL)
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				utils.HandleErr(err, utils.Indexer, "Failed to get product", "url", page.URL)
+				failed++
+				continue
+			}
+
+			diff := diffFields(existing, product)
+
+			if len(diff) == 0 {
+				unchanged++
+				continue
+			}
+
+			updated++
+
+			fmt.Println(page.URL)
+			for _, line := range diff {
+				fmt.Println("  " + line)
+			}
+
+			continue
+		}
+
+		err = db.ReindexProduct(product)
+		if utils.HandleErr(err, utils.Indexer, "Failed to update product", "url", page.URL) {
+			failed++
+			continue
+		}
+
+		updated++
+	}
+
+	fmt.Printf("%d updated, %d unchanged, %d failed\n", updated, unchanged, failed)
+
+	return nil
+}
+
+// findTarget finds a target by name, ignoring case.
+func findTarget(all []data.Target, name string) (data.Target, bool) {
+	for _, target := range all {
+		if strings.EqualFold(target.Target, name) {
+			return target, true
+		}
+	}
+
+	return data.Target{}, false
+}
+
+// diffFields lists the fields of `updated` that differ from `existing`,
+// one "label: old -> new" line per field.
+func diffFields(existing, updated map[string]interface{}) []string {
+	labels := []string{}
+
+	for label := range updated {
+		if label == "slug" {
+			continue
+		}
+
+		labels = append(labels, label)
+	}
+
+	sort.Strings(labels)
+
+	diff := []string{}
+
+	for _, label := range labels {
+		before, found := existing[label]
+
+		oldValue := "<missing>"
+		if found {
+			oldValue = fmt.Sprintf("%v", before)
+		}
+
+		newValue := fmt.Sprintf("%v", updated[label])
+
+		if oldValue != newValue {
+			diff = append(diff, fmt.Sprintf("%s: %q -> %q", label, oldValue, newValue))
+		}
+	}
+
+	return diff
+}
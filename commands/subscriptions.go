@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+)
+
+// Subscribe adds a price alert subscription.
+//
+// Usage: subscribe (--url <product url> | --query "iphone 13" [--source Jiji]) --below 1200 --notify webhook:https://...
+func Subscribe(db *database.Database, args []string) error {
+	flags := flag.NewFlagSet("subscribe", flag.ContinueOnError)
+
+	url := flags.String("url", "", "URL of the product to watch")
+	query := flags.String("query", "", "watch every product whose name contains all of these words")
+	source := flags.String("source", "", "only match --query against products of this source, e.g. Jumia")
+	below := flags.Float64("below", 0, "alert when the price is at or below this amount")
+	notify := flags.String("notify", "", "where to deliver alerts, webhook:<url> or email:<address>")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if (*url == "") == (*query == "") {
+		return errors.New("subscribe needs exactly one of --url and --query")
+	}
+
+	if *below <= 0 {
+		return errors.New("subscribe needs a positive --below")
+	}
+
+	channel, recipient, found := strings.Cut(*notify, ":")
+	if !found || recipient == "" || (channel != "webhook" && channel != "email") {
+		return errors.New("subscribe needs --notify webhook:<url> or email:<address>")
+	}
+
+	sub, err := db.AddSubscription(data.Subscription{
+		ProductID: *url,
+		Query:     *query,
+		Source:    *source,
+		Threshold: *below,
+		Notify:    *notify,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Subscribed:", sub.ID)
+
+	return nil
+}
+
+// Unsubscribe deletes a price alert subscription.
+//
+// Usage: unsubscribe --id <subscription id>
+func Unsubscribe(db *database.Database, args []string) error {
+	flags := flag.NewFlagSet("unsubscribe", flag.ContinueOnError)
+
+	id := flags.String("id", "", "ID of the subscription")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *id == "" {
+		return errors.New("unsubscribe needs --id")
+	}
+
+	return db.DeleteSubscription(*id)
+}
+
+// Subscriptions lists every price alert subscription.
+func Subscriptions(db *database.Database, args []string) error {
+	subs, err := db.GetSubscriptions()
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		watching := sub.ProductID
+		if watching == "" {
+			watching = fmt.Sprintf("query %q", sub.Query)
+
+			if sub.Source != "" {
+				watching += " on " + sub.Source
+			}
+		}
+
+		fmt.Printf("%s  %s  below %.2f  -> %s\n", sub.ID, watching, sub.Threshold, sub.Notify)
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+	"github.com/Cedi-Search/Cedi-Search-Engine/targets"
+)
+
+// ValidateTargets checks the target files of a directory and prints every
+// problem with its file, line and column, so target changes can be checked in CI.
+//
+// Usage: validate-targets [--dir targets]
+func ValidateTargets(db *database.Database, args []string) error {
+	flags := flag.NewFlagSet("validate-targets", flag.ContinueOnError)
+	dir := flags.String("dir", os.Getenv("TARGETS_DIR"), "directory of target files, defaults to TARGETS_DIR")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *dir == "" {
+		return fmt.Errorf("validate-targets needs --dir or TARGETS_DIR")
+	}
+
+	loaded, errs := targets.LoadDir(*dir)
+
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w in %s: %d problems", targets.ErrInvalidTargets, *dir, len(errs))
+	}
+
+	fmt.Printf("%d targets in %s are valid\n", len(loaded), *dir)
+
+	return nil
+}
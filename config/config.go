@@ -1,5 +1,76 @@
 package config
 
+import "time"
+
 const (
 	USER_AGENT = "daxsome-wizard/0.1 (+https://daxsome.owbird.site/bot)"
+
+	// Sniffer limits. A cycle walks at most SNIFF_MAX_PAGES listing pages
+	// no deeper than SNIFF_MAX_DEPTH links away from the target's seed path.
+	SNIFF_MAX_DEPTH     = 3
+	SNIFF_MAX_PAGES     = 50
+	SNIFF_PAGE_DELAY    = 10 * time.Second
+	SNIFF_CYCLE_DELAY   = 30 * time.Minute
+	SNIFF_REVISIT_AFTER = 24 * time.Hour
+
+	// Recrawl intervals adapt to how often a product's price or availability
+	// changes, starting at RECRAWL_DEFAULT_INTERVAL. Targets can narrow the bounds.
+	RECRAWL_DEFAULT_INTERVAL = 24 * time.Hour
+	RECRAWL_MIN_INTERVAL     = 3 * time.Hour
+	RECRAWL_MAX_INTERVAL     = 14 * 24 * time.Hour
+
+	// Every RECRAWL_TICK, at most RECRAWL_BATCH due products per target are queued,
+	// and none while the target's queue holds RECRAWL_MAX_QUEUED URLs or more,
+	// so recrawls never outpace the crawler's politeness delay.
+	RECRAWL_TICK       = 10 * time.Minute
+	RECRAWL_BATCH      = 20
+	RECRAWL_MAX_QUEUED = 50
+
+	// How long the crawler waits between batches of a target's queue.
+	CRAWL_DELAY = 30 * time.Second
+
+	// A URL that fails to crawl is retried QUEUE_RETRY_BASE later, doubling with
+	// every failure up to QUEUE_RETRY_MAX. After QUEUE_MAX_ATTEMPTS failures it's
+	// moved to the dead letters.
+	QUEUE_MAX_ATTEMPTS = 5
+	QUEUE_RETRY_BASE   = 5 * time.Minute
+	QUEUE_RETRY_MAX    = 6 * time.Hour
+
+	// A fetch gives up after FETCH_TIMEOUT, whether it's a plain HTTP request
+	// or a browser page that never finishes loading.
+	FETCH_TIMEOUT = 60 * time.Second
+
+	// A proxy that gets blocked sits out PROXY_BENCH_TIME, doubling with every
+	// block in a row up to PROXY_MAX_BENCH_TIME. Once its bench is over, it's
+	// probed every PROXY_CHECK_INTERVAL until it gets through again. Per-proxy
+	// counts are flushed to the database every PROXY_STATS_FLUSH.
+	PROXY_BENCH_TIME     = 15 * time.Minute
+	PROXY_MAX_BENCH_TIME = 12 * time.Hour
+	PROXY_CHECK_INTERVAL = time.Minute
+	PROXY_STATS_FLUSH    = 5 * time.Minute
+
+	// Pages smaller than BLOCK_MIN_BODY bytes are taken for empty shells served
+	// to bots. A blocked source cools down for BLOCK_COOLDOWN, doubling with
+	// every block in a row up to BLOCK_MAX_COOLDOWN.
+	BLOCK_MIN_BODY     = 2048
+	BLOCK_COOLDOWN     = 30 * time.Minute
+	BLOCK_MAX_COOLDOWN = 24 * time.Hour
+
+	// Field fill rates are measured over a source's last HEALTH_WINDOW pages and
+	// checked every HEALTH_CHECK_INTERVAL once there are HEALTH_MIN_PAGES of them.
+	// A field alerts when its fill rate falls below HEALTH_ALERT_RATIO of its
+	// baseline, or a required field's below HEALTH_MIN_FILL_RATE, with up to
+	// HEALTH_SAMPLE_URLS pages it failed on.
+	HEALTH_WINDOW         = 200
+	HEALTH_MIN_PAGES      = 30
+	HEALTH_CHECK_INTERVAL = 5 * time.Minute
+	HEALTH_ALERT_RATIO    = 0.8
+	HEALTH_MIN_FILL_RATE  = 0.8
+	HEALTH_SAMPLE_URLS    = 5
+
+	// How often the queue depth metric is refreshed.
+	METRICS_QUEUE_INTERVAL = 30 * time.Second
+
+	// How often products are re-matched into cross-source groups.
+	MATCH_INTERVAL = 6 * time.Hour
 )
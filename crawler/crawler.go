@@ -1,15 +1,26 @@
 package crawler
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"net/http"
+	netURL "net/url"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/Cedi-Search/Cedi-Search-Engine/archive"
+	"github.com/Cedi-Search/Cedi-Search-Engine/config"
 	"github.com/Cedi-Search/Cedi-Search-Engine/data"
 	"github.com/Cedi-Search/Cedi-Search-Engine/database"
 	"github.com/Cedi-Search/Cedi-Search-Engine/indexer"
+	"github.com/Cedi-Search/Cedi-Search-Engine/sniffer"
+	"github.com/Cedi-Search/Cedi-Search-Engine/tracing"
 	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
 	"github.com/anaskhan96/soup"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Crawler struct {
@@ -28,68 +39,307 @@ func NewCrawler(database *database.Database) *Crawler {
 	}
 }
 
-// Crawl performs crawling operation.
+// AddExtractionObserver registers an observer of every page the crawler indexes.
+func (cr *Crawler) AddExtractionObserver(observer indexer.ExtractionObserver) {
+	cr.indexer.AddExtractionObserver(observer)
+}
+
+// Run crawls a target's queue batch after batch until the process is stopped,
+// waiting config.CRAWL_DELAY between batches.
+func (cr *Crawler) Run(target data.Target) {
+	for {
+		cr.Crawl(target)
+
+		utils.Log(utils.Crawler).Debug("Waiting to continue crawling", "source", target.Target, "delay", config.CRAWL_DELAY)
+		time.Sleep(config.CRAWL_DELAY)
+	}
+}
+
+// Crawl performs a single crawling pass.
 //
 // It retrieves URLs from the database queue and starts crawling each URL concurrently.
-// For each URL, it fetches the page content, parses it, saves the HTML to the database,
-// and deletes the URL from the queue. Once all URLs have been crawled, it waits for 30 seconds
-// before calling itself recursively to continue the crawling process.
+// For each URL, it fetches the page content, archives the response, indexes it,
+// and deletes the URL from the queue.
+//
+// Nothing is crawled while the target is cooling down after serving a block page.
+// Block pages leave their URL queued. Other failures, including pages missing
+// required fields, are retried with exponential backoff until the URL is dead-lettered.
 func (cr *Crawler) Crawl(target data.Target) {
+	state, err := cr.db.GetBlockState(target.Target)
+	if utils.HandleErr(err, utils.Crawler, "Failed to get block state for crawler") {
+		return
+	}
+
+	if time.Now().Before(state.BlockedUntil) {
+		utils.Log(utils.Crawler).Info("Source is blocked, cooling down", "source", target.Target, "reason", state.Reason, "until", state.BlockedUntil)
+		return
+	}
+
 	queue, err := cr.db.GetQueue(target.Target)
-	if utils.HandleErr(err, "Failed to get pages for crawler") {
+	if utils.HandleErr(err, utils.Crawler, "Failed to get pages for crawler") {
 		return
 	}
 
 	if len(queue) == 0 {
-		utils.Logger(utils.Crawler, utils.Crawler, "Queue is empty for ", target.Target)
+		utils.Log(utils.Crawler).Debug("Queue is empty", "source", target.Target)
 		return
 	}
 
 	wg := sync.WaitGroup{}
 
+	// The source served a real page, so a block streak from an earlier batch is over.
+	clearBlocked := sync.OnceFunc(func() {
+		err := cr.db.ClearBlocked(target.Target)
+		utils.HandleErr(err, utils.Crawler, "Failed to clear block state", "source", target.Target)
+	})
+
 	for _, url := range queue {
 
 		wg.Add(1)
 		go func(url data.UrlQueue) {
 			defer wg.Done()
 
-			utils.Logger(utils.Crawler, utils.Crawler, "Crawling: ", url.URL)
+			ctx, span := tracing.Start(tracing.Extract(context.Background(), url.TraceParent), "crawl", trace.WithAttributes(
+				attribute.String("source", url.Source),
+				attribute.String("url", url.URL),
+				attribute.Int("attempt", url.Attempts+1),
+			))
+			defer span.End()
+
+			log := utils.Log(utils.Crawler).With("source", url.Source, "url", url.URL, "attempt", url.Attempts+1, "trace_id", tracing.TraceID(ctx))
+
+			log.Debug("Crawling")
+
+			fetcher := Fetcher(url.Source)
+
+			var resp utils.Response
+			var err error
+
+			// Conditional requests go through the plain HTTP client, so only sources
+			// fetched with it are revalidated; the rest would need a second fetch.
+			if url.Recrawl && fetcher == "soup" {
+				revalidated, notModified, err := cr.revalidate(ctx, url)
+				utils.HandleErr(err, utils.Crawler, "Failed to revalidate", "source", url.Source, "url", url.URL)
+
+				if notModified {
+					err = cr.db.DeleteFromQueue(url)
+					utils.HandleErr(err, utils.Crawler, "Failed to delete from crawler queue", "source", url.Source, "url", url.URL)
+
+					log.Info("Not modified")
+
+					return
+				}
+
+				// The conditional request already got us the page.
+				if revalidated != nil {
+					resp = *revalidated
+				}
+			}
+
+			if resp.Body == "" {
+				_, fetchSpan := tracing.Start(ctx, "fetch", trace.WithAttributes(attribute.String("fetcher", fetcher)))
+
+				resp, err = utils.Fetch(url.URL, fetcher)
 
-			var resp string
+				fetchSpan.SetAttributes(attribute.Int("http.status_code", resp.Status), attribute.String("final_url", resp.FinalURL))
+				tracing.End(fetchSpan, err)
 
-			if url.Source == "Jiji" || url.Source == "Deus" {
-				resp = utils.FetchPage(url.URL, "soup")
-			} else {
-				resp = utils.FetchPage(url.URL, "rod")
+				if utils.HandleErr(err, utils.Crawler, "Failed to fetch", "source", url.Source, "url", url.URL) {
+					span.RecordError(err)
+					cr.fail(url, err)
+					return
+				}
+			}
+
+			if reason := utils.DetectBlock(resp); reason != "" {
+				span.SetAttributes(attribute.String("blocked", reason))
+				cr.block(target, url, reason)
+				return
 			}
 
-			doc := soup.HTMLParse(resp)
+			if isRemoved(target, resp) {
+				log.Info("Product is gone")
+
+				span.SetAttributes(attribute.Bool("removed", true))
+
+				err = cr.db.MarkProductRemoved(url.URL)
+				if utils.HandleErr(err, utils.Crawler, "Failed to mark as removed", "source", url.Source, "url", url.URL) {
+					cr.fail(url, err)
+					return
+				}
+
+				err = cr.db.DeleteFromQueue(url)
+				utils.HandleErr(err, utils.Crawler, "Failed to delete from crawler queue", "source", url.Source, "url", url.URL)
+
+				return
+			}
+
+			doc := soup.HTMLParse(resp.Body)
 
 			page := data.CrawledPage{
-				URL:     url.URL,
-				HTML:    doc.HTML(),
-				Source:  url.Source,
-				Attribs: target.Data,
+				URL:          url.URL,
+				HTML:         doc.HTML(),
+				Source:       url.Source,
+				Status:       resp.Status,
+				Fetcher:      resp.Fetcher,
+				FetchedAt:    resp.FetchedAt,
+				ETag:         resp.Header.Get("ETag"),
+				LastModified: resp.Header.Get("Last-Modified"),
+				Size:         len(resp.Body),
+				TraceID:      tracing.TraceID(ctx),
+				Attribs:      target.Data,
+			}
+
+			ref, err := archive.Default().Store(url.Source, resp)
+			if !utils.HandleErr(err, utils.Crawler, "Failed to archive", "source", url.Source, "url", url.URL) {
+				page.WarcFile = ref.File
+				page.WarcOffset = ref.Offset
+
+				err = cr.db.SaveCrawledPage(page)
+				utils.HandleErr(err, utils.Crawler, "Failed to save crawled page", "source", url.Source, "url", url.URL)
 			}
 
-			err = cr.indexer.Index(page)
-			if utils.HandleErr(err, fmt.Sprintf("Failed to index: %v", url)) {
+			err = cr.indexer.Index(ctx, page)
+
+			// Block pages were caught above, so a page missing required fields is a
+			// problem of that page (or of the target's selectors), not a block.
+			if utils.HandleErr(err, utils.Crawler, "Failed to index", "source", url.Source, "url", url.URL) {
+				span.RecordError(err)
+				cr.fail(url, err)
 				return
 			}
 
+			if state.Blocks > 0 {
+				clearBlocked()
+			}
+
 			err = cr.db.DeleteFromQueue(url)
-			if utils.HandleErr(err, fmt.Sprintf("Failed to delete from crawler queue: %v", url)) {
+			if utils.HandleErr(err, utils.Crawler, "Failed to delete from crawler queue", "source", url.Source, "url", url.URL) {
 				return
 			}
 
-			utils.Logger(utils.Crawler, utils.Crawler, "Crawled: ", url.URL)
+			log.Info("Crawled")
 		}(url)
 	}
 
 	wg.Wait()
+}
+
+// Fetcher returns the fetcher a source's pages are fetched with: the plain
+// HTTP client for shops that render server-side, the headless browser otherwise.
+func Fetcher(source string) string {
+	if source == "Jiji" || source == "Deus" {
+		return "soup"
+	}
+
+	return "rod"
+}
+
+// fail records a failed crawl of a queued URL so it's retried later,
+// or dead-lettered once it has failed config.QUEUE_MAX_ATTEMPTS times.
+func (cr *Crawler) fail(url data.UrlQueue, cause error) {
+	url, dead, err := cr.db.FailQueued(url, cause)
+	if utils.HandleErr(err, utils.Crawler, "Failed to record failed crawl", "source", url.Source, "url", url.URL) {
+		return
+	}
+
+	log := utils.Log(utils.Crawler).With("source", url.Source, "url", url.URL, "attempt", url.Attempts)
+
+	if dead {
+		log.Error("Gave up on URL", "error", cause)
+		return
+	}
+
+	log.Warn("Will retry URL", "error", cause, "next_attempt_at", url.NextAttemptAt)
+}
+
+// block puts a target into a cooldown after one of its pages came back as a block page.
+// The URL stays queued so it's crawled again once the cooldown is over.
+func (cr *Crawler) block(target data.Target, url data.UrlQueue, reason string) {
+	state, err := cr.db.MarkBlocked(target.Target, target.Host, url.URL, reason)
+	if utils.HandleErr(err, utils.Crawler, "Failed to mark as blocked", "source", target.Target) {
+		return
+	}
+
+	utils.Log(utils.Crawler).Warn("Blocked", "source", target.Target, "url", url.URL, "reason", reason, "until", state.BlockedUntil)
+}
+
+// revalidate makes a conditional request for a recrawled URL using the validators
+// of its last crawl. On 304 it bumps the product's last_seen and reports notModified.
+//
+// The returned response is the full page when the shop sent one, or nil when
+// there was nothing to revalidate with or the request failed.
+func (cr *Crawler) revalidate(ctx context.Context, url data.UrlQueue) (*utils.Response, bool, error) {
+	previous, err := cr.db.GetLatestCrawledPage(url.URL)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if previous.ETag == "" && previous.LastModified == "" {
+		return nil, false, nil
+	}
+
+	_, span := tracing.Start(ctx, "fetch.revalidate")
+
+	resp, err := utils.FetchIfModified(url.URL, previous.ETag, previous.LastModified)
+
+	span.SetAttributes(attribute.Int("http.status_code", resp.Status))
+	tracing.End(span, err)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	notModified := resp.Status == http.StatusNotModified
+
+	err = cr.db.RecordRevalidation(url.Source, notModified, previous.Size)
+	utils.HandleErr(err, utils.Crawler, "Failed to record revalidation", "source", url.Source, "url", url.URL)
+
+	if notModified {
+		return nil, true, cr.db.TouchProduct(url.URL)
+	}
+
+	if resp.Status != http.StatusOK {
+		return nil, false, nil
+	}
+
+	return &resp, false, nil
+}
+
+// isRemoved reports whether a product page no longer exists: the shop
+// answered 404/410, or redirected us to its home page, the target's seed path
+// or a listing page instead. Other redirects, like http to https or a renamed
+// slug, still lead to the product.
+func isRemoved(target data.Target, resp utils.Response) bool {
+	if resp.Status == http.StatusNotFound || resp.Status == http.StatusGone {
+		return true
+	}
+
+	requested, err := utils.CanonicalURL(resp.URL)
+	if err != nil || resp.FinalURL == "" {
+		return false
+	}
+
+	final, err := utils.CanonicalURL(resp.FinalURL)
+	if err != nil || final == requested {
+		return false
+	}
+
+	finalURL, err := netURL.Parse(resp.FinalURL)
+	if err != nil {
+		return false
+	}
+
+	path := strings.TrimSuffix(finalURL.Path, "/")
+	if path == "" || path == strings.TrimSuffix(target.SeedPath, "/") {
+		return true
+	}
 
-	utils.Logger(utils.Crawler, utils.Crawler, "Wait 30s to continue crawling")
-	time.Sleep(30 * time.Second)
+	classifier, _ := sniffer.NewClassifier(target)
 
-	// cr.Crawl(source, indexer)
+	return classifier.Classify(finalURL, soup.Root{}) == sniffer.Listing
 }
@@ -0,0 +1,181 @@
+package currency
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+)
+
+// Base is the currency every price is normalised to.
+const Base = "GHS"
+
+// markers maps the currency markers shops put next to prices to ISO 4217 codes.
+// Longer markers come first so "GH₵" wins over "₵" and "US$" over "$".
+var markers = []struct {
+	marker   string
+	currency string
+}{
+	{"GH₵", "GHS"}, {"GH¢", "GHS"}, {"GHS", "GHS"}, {"GHC", "GHS"}, {"₵", "GHS"}, {"¢", "GHS"}, {"CEDIS", "GHS"},
+	{"US$", "USD"}, {"USD", "USD"}, {"$", "USD"},
+	{"EUR", "EUR"}, {"€", "EUR"},
+	{"GBP", "GBP"}, {"£", "GBP"},
+	{"NGN", "NGN"}, {"₦", "NGN"},
+}
+
+var amountRe = regexp.MustCompile(`\d[\d,]*(\.\d+)?`)
+
+// Parse reads a price as shops display it, e.g. "GH₵ 1,150.50" or "$ 1,200".
+//
+// Prices without a currency marker are taken to be in cedis. When a text holds
+// a range, e.g. "GH₵ 1,150 - GH₵ 1,300", the first amount is used.
+func Parse(text string) (data.Price, error) {
+	price := data.Price{
+		Original: strings.TrimSpace(text),
+		Currency: Base,
+	}
+
+	match := amountRe.FindStringIndex(text)
+	if match == nil {
+		return price, fmt.Errorf("no amount in price %q", text)
+	}
+
+	// Only look for a marker before or just after the first amount.
+	window := strings.ToUpper(text[:min(len(text), match[1]+6)])
+
+	for _, m := range markers {
+		if strings.Contains(window, m.marker) {
+			price.Currency = m.currency
+			break
+		}
+	}
+
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(text[match[0]:match[1]], ",", ""), 64)
+	if err != nil {
+		return price, err
+	}
+
+	price.Amount = ToMinor(amount)
+
+	return price, nil
+}
+
+// ParseNormalized parses a price and fills in its cedi amount.
+func ParseNormalized(text string) (data.Price, error) {
+	price, err := Parse(text)
+	if err != nil {
+		return price, err
+	}
+
+	err = Normalize(&price)
+
+	return price, err
+}
+
+// DiscountPercent is how much cheaper a price is than the old price,
+// rounded to one decimal place. It is 0 when there is no real discount.
+func DiscountPercent(price, oldPrice float64) float64 {
+	if price <= 0 || oldPrice <= price {
+		return 0
+	}
+
+	return math.Round((oldPrice-price)/oldPrice*1000) / 10
+}
+
+// ToMinor converts an amount in major units to minor units, e.g. cedis to pesewas.
+func ToMinor(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// ToMajor converts an amount in minor units to major units, e.g. pesewas to cedis.
+func ToMajor(amount int64) float64 {
+	return float64(amount) / 100
+}
+
+// Rates is an exchange-rate table: how many cedis one unit of each currency buys.
+type Rates struct {
+	UpdatedAt string             `json:"updated_at"`
+	Rates     map[string]float64 `json:"rates"`
+}
+
+var rates Rates
+var ratesErr error
+var ratesOnce sync.Once
+
+// LoadRates reads an exchange-rate table from a JSON file such as rates.json.
+func LoadRates(file string) (Rates, error) {
+	table := Rates{}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return table, err
+	}
+
+	err = json.Unmarshal(content, &table)
+	if err != nil {
+		return table, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return table, nil
+}
+
+// defaultRates loads the table named by the RATES_FILE environment
+// variable, or rates.json, the first time it is needed.
+func defaultRates() (Rates, error) {
+	ratesOnce.Do(func() {
+		file := os.Getenv("RATES_FILE")
+		if file == "" {
+			file = "rates.json"
+		}
+
+		rates, ratesErr = LoadRates(file)
+	})
+
+	return rates, ratesErr
+}
+
+// Convert converts an amount in minor units of `from` to minor units of cedis.
+func (table Rates) Convert(amount int64, from string) (int64, error) {
+	if from == Base {
+		return amount, nil
+	}
+
+	rate, found := table.Rates[from]
+	if !found || rate <= 0 {
+		return 0, fmt.Errorf("no exchange rate for %s", from)
+	}
+
+	return int64(math.Round(float64(amount) * rate)), nil
+}
+
+// Normalize fills in a price's cedi amount using the default exchange-rate table.
+func Normalize(price *data.Price) error {
+	if price.Currency == Base {
+		price.AmountGHS = price.Amount
+		price.OldAmountGHS = price.OldAmount
+		return nil
+	}
+
+	table, err := defaultRates()
+	if err != nil {
+		return err
+	}
+
+	price.AmountGHS, err = table.Convert(price.Amount, price.Currency)
+	if err != nil {
+		return err
+	}
+
+	price.OldAmountGHS, err = table.Convert(price.OldAmount, price.Currency)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
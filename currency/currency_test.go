@@ -0,0 +1,172 @@
+package currency
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text     string
+		currency string
+		amount   int64
+	}{
+		{"GH₵ 1,150.50", "GHS", 115050},
+		{"gh₵1,150", "GHS", 115000},
+		{"GH¢ 25", "GHS", 2500},
+		{"GHS 0.1", "GHS", 10},
+		{"₵ 99", "GHS", 9900},
+		{"1200 cedis", "GHS", 120000},
+		{"1,150", "GHS", 115000},
+		{"$ 1,200", "USD", 120000},
+		{"US$ 19.99", "USD", 1999},
+		{"1,200 USD", "USD", 120000},
+		{"€ 5", "EUR", 500},
+		{"£5.25", "GBP", 525},
+		{"₦ 25,000", "NGN", 2500000},
+		// The first amount of a range.
+		{"GH₵ 1,150 - GH₵ 1,300", "GHS", 115000},
+		// Markers far after the amount aren't about it.
+		{"1,200 in stock, ships in USD", "GHS", 120000},
+	}
+
+	for _, test := range tests {
+		price, err := Parse(test.text)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.text, err)
+			continue
+		}
+
+		if price.Currency != test.currency || price.Amount != test.amount {
+			t.Errorf("Parse(%q) = %s %d, want %s %d", test.text, price.Currency, price.Amount, test.currency, test.amount)
+		}
+
+		if price.Original != strings.TrimSpace(test.text) {
+			t.Errorf("Parse(%q) kept %q as the original", test.text, price.Original)
+		}
+	}
+
+	if _, err := Parse("Call for price"); err == nil {
+		t.Error("Parse of a price without an amount succeeded")
+	}
+}
+
+func TestMinorUnits(t *testing.T) {
+	tests := []struct {
+		major float64
+		minor int64
+	}{
+		{0, 0},
+		{1150.5, 115050},
+		{19.99, 1999},
+		{0.1 + 0.2, 30},
+		{0.005, 1},
+		{0.004, 0},
+		{1234567.89, 123456789},
+	}
+
+	for _, test := range tests {
+		if got := ToMinor(test.major); got != test.minor {
+			t.Errorf("ToMinor(%v) = %d, want %d", test.major, got, test.minor)
+		}
+	}
+
+	for _, amount := range []int64{0, 1, 1999, 115050, 123456789} {
+		if got := ToMinor(ToMajor(amount)); got != amount {
+			t.Errorf("ToMinor(ToMajor(%d)) = %d", amount, got)
+		}
+	}
+}
+
+func TestDiscountPercent(t *testing.T) {
+	tests := []struct {
+		price, oldPrice float64
+		want            float64
+	}{
+		{1000, 1500, 33.3},
+		{750, 1000, 25},
+		{1000, 1000, 0},
+		{1500, 1000, 0},
+		{0, 1000, 0},
+	}
+
+	for _, test := range tests {
+		if got := DiscountPercent(test.price, test.oldPrice); got != test.want {
+			t.Errorf("DiscountPercent(%v, %v) = %v, want %v", test.price, test.oldPrice, got, test.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	table := Rates{Rates: map[string]float64{"USD": 15.5, "NGN": 0.0095, "EUR": 0}}
+
+	tests := []struct {
+		amount int64
+		from   string
+		want   int64
+		fails  bool
+	}{
+		{115050, "GHS", 115050, false},
+		{1999, "USD", 30985, false},
+		{2500000, "NGN", 23750, false},
+		{500, "EUR", 0, true},
+		{500, "GBP", 0, true},
+	}
+
+	for _, test := range tests {
+		got, err := table.Convert(test.amount, test.from)
+		if (err != nil) != test.fails {
+			t.Errorf("Convert(%d, %s) error = %v, want failure %v", test.amount, test.from, err, test.fails)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("Convert(%d, %s) = %d, want %d", test.amount, test.from, got, test.want)
+		}
+	}
+}
+
+func TestLoadRates(t *testing.T) {
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "rates.json")
+	err := os.WriteFile(file, []byte(`{"updated_at": "2024-06-01", "rates": {"USD": 15.5}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := LoadRates(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if table.UpdatedAt != "2024-06-01" || table.Rates["USD"] != 15.5 {
+		t.Errorf("LoadRates = %+v", table)
+	}
+
+	broken := filepath.Join(dir, "broken.json")
+	err = os.WriteFile(broken, []byte(`{"rates": {"USD": "15.5"}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadRates(broken); err == nil || !strings.Contains(err.Error(), broken) {
+		t.Errorf("LoadRates of a broken table = %v, want an error naming the file", err)
+	}
+}
+
+func TestNormalizeCedis(t *testing.T) {
+	price := data.Price{Amount: 115050, OldAmount: 130000, Currency: Base}
+
+	if err := Normalize(&price); err != nil {
+		t.Fatal(err)
+	}
+
+	if price.AmountGHS != 115050 || price.OldAmountGHS != 130000 {
+		t.Errorf("Normalize = %d, %d; want the amounts as they are", price.AmountGHS, price.OldAmountGHS)
+	}
+}
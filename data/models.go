@@ -1,27 +1,244 @@
 package data
 
+import "time"
+
 type UrlQueue struct {
-	ID     string `bson:"_id"`
-	URL    string `bson:"url"`
-	Source string `bson:"source"`
+	ID      string `bson:"_id"`
+	URL     string `bson:"url"`
+	Source  string `bson:"source"`
+	Recrawl bool   `bson:"recrawl"`
+	// Attempts counts the failed crawls of the URL. After a failure it isn't
+	// crawled again before NextAttemptAt.
+	Attempts      int       `bson:"attempts,omitempty"`
+	LastError     string    `bson:"last_error,omitempty"`
+	NextAttemptAt time.Time `bson:"next_attempt_at,omitempty"`
+	// TraceParent is the W3C traceparent of the span that queued the URL,
+	// so its crawl continues the same trace.
+	TraceParent string `bson:"traceparent,omitempty"`
 }
 
+// DeadLetter is a queued URL that kept failing and was taken off the queue.
+type DeadLetter struct {
+	UrlQueue `bson:",inline"`
+	DiedAt   time.Time `bson:"died_at"`
+}
+
+// CrawledPage is a fetched product page. The HTML itself lives in the
+// WARC archive; WarcFile and WarcOffset point to its record.
 type CrawledPage struct {
-	URL     string `bson:"url"`
-	HTML    string `bson:"html"`
-	Source  string `bson:"source"`
-	Attribs []Data
+	URL          string    `bson:"url"`
+	HTML         string    `bson:"html,omitempty"`
+	Source       string    `bson:"source"`
+	Status       int       `bson:"status"`
+	Fetcher      string    `bson:"fetcher"`
+	FetchedAt    time.Time `bson:"fetched_at"`
+	WarcFile     string    `bson:"warc_file"`
+	WarcOffset   int64     `bson:"warc_offset"`
+	ETag         string    `bson:"etag,omitempty"`
+	LastModified string    `bson:"last_modified,omitempty"`
+	Size         int       `bson:"size"`
+	TraceID      string    `bson:"trace_id,omitempty"`
+	Attribs      []Data    `bson:"-"`
+}
+
+// RevalidationStats counts conditional recrawls of a source and
+// how many of them the shop answered with 304 Not Modified.
+type RevalidationStats struct {
+	Source      string `bson:"_id"`
+	Requests    int64  `bson:"requests"`
+	NotModified int64  `bson:"not_modified"`
+	BytesSaved  int64  `bson:"bytes_saved"`
+}
+
+// ProxyStats counts the fetches made through a proxy and how they went.
+// Blocks are responses the shop turned us away with, e.g. 403s or captchas.
+type ProxyStats struct {
+	Proxy     string `bson:"_id"`
+	Requests  int64  `bson:"requests"`
+	Successes int64  `bson:"successes"`
+	Failures  int64  `bson:"failures"`
+	Blocks    int64  `bson:"blocks"`
+}
+
+// BlockState is the latest block a source served us. The source is left
+// alone until BlockedUntil; Blocks counts the blocks in a row.
+type BlockState struct {
+	Source       string    `bson:"_id"`
+	Host         string    `bson:"host"`
+	Reason       string    `bson:"reason"`
+	URL          string    `bson:"url"`
+	Blocks       int       `bson:"blocks"`
+	Since        time.Time `bson:"since"`
+	BlockedUntil time.Time `bson:"blocked_until"`
+}
+
+// FieldHealth is how often a target's field was extracted over the latest
+// pages (FillRate) compared with how often it usually is (Baseline).
+// Alerting is set while the field is considered broken.
+type FieldHealth struct {
+	ID          string    `bson:"_id" json:"-"`
+	Source      string    `bson:"source" json:"source"`
+	Field       string    `bson:"field" json:"field"`
+	FillRate    float64   `bson:"fill_rate" json:"fill_rate"`
+	Baseline    float64   `bson:"baseline" json:"baseline"`
+	Pages       int       `bson:"pages" json:"pages"`
+	FailingURLs []string  `bson:"failing_urls" json:"failing_urls"`
+	Alerting    bool      `bson:"alerting" json:"alerting"`
+	CheckedAt   time.Time `bson:"checked_at" json:"checked_at"`
+}
+
+// SniffedPage is a listing page the sniffer has visited, together
+// with the listing links it found there.
+type SniffedPage struct {
+	ID        string    `bson:"_id"`
+	Source    string    `bson:"source"`
+	Path      string    `bson:"path"`
+	Depth     int       `bson:"depth"`
+	Listings  []string  `bson:"listings"`
+	SniffedAt time.Time `bson:"sniffed_at"`
+}
+
+// Availability of a product, as normalised from schema.org values or target selectors.
+const (
+	InStock    = "in_stock"
+	OutOfStock = "out_of_stock"
+	PreOrder   = "preorder"
+	Removed    = "removed"
+)
+
+// Price is a price as listed by a shop. Amounts are in minor units
+// (pesewas, cents) of Currency; the GHS amounts are converted for comparison.
+type Price struct {
+	Amount       int64  `bson:"amount" json:"amount"`
+	Currency     string `bson:"currency" json:"currency"`
+	Original     string `bson:"original" json:"original"`
+	OldAmount    int64  `bson:"old_amount,omitempty" json:"old_amount,omitempty"`
+	AmountGHS    int64  `bson:"amount_ghs" json:"amount_ghs"`
+	OldAmountGHS int64  `bson:"old_amount_ghs,omitempty" json:"old_amount_ghs,omitempty"`
 }
 
 type Product struct {
-	Slug        string   `bson:"slug" json:"slug"`
-	Name        string   `bson:"name" json:"name"`
-	Price       float64  `bson:"price" json:"price"`
-	Rating      float64  `bson:"rating" json:"rating"`
-	Description string   `bson:"description" json:"description"`
-	URL         string   `bson:"url" json:"url"`
-	Source      string   `bson:"source" json:"source"`
-	Images      []string `bson:"images" json:"images"`
+	Slug            string   `bson:"slug" json:"slug"`
+	Name            string   `bson:"name" json:"name"`
+	Price           float64  `bson:"price" json:"price"`
+	PriceInfo       Price    `bson:"price_info" json:"price_info"`
+	OldPrice        float64  `bson:"old_price" json:"old_price"`
+	DiscountPercent float64  `bson:"discount_percent" json:"discount_percent"`
+	Promotions      []string `bson:"promotions" json:"promotions"`
+	Availability    string   `bson:"availability" json:"availability"`
+	Rating          float64  `bson:"rating" json:"rating"`
+	Description     string   `bson:"description" json:"description"`
+	URL             string   `bson:"url" json:"url"`
+	Source          string   `bson:"source" json:"source"`
+	Images          []string `bson:"images" json:"images"`
+}
+
+// Fields returns the product as the field map database.IndexProduct takes,
+// the same shape the indexer extracts.
+func (product Product) Fields() map[string]interface{} {
+	return map[string]interface{}{
+		"name":             product.Name,
+		"price":            product.Price,
+		"price_info":       product.PriceInfo,
+		"old_price":        product.OldPrice,
+		"discount_percent": product.DiscountPercent,
+		"promotions":       product.Promotions,
+		"availability":     product.Availability,
+		"rating":           product.Rating,
+		"description":      product.Description,
+		"url":              product.URL,
+		"source":           product.Source,
+		"images":           product.Images,
+	}
+}
+
+// PricePoint is a single observed price of a product.
+type PricePoint struct {
+	ProductID  string    `bson:"product_id" json:"product_id"`
+	Price      float64   `bson:"price" json:"price"`
+	Source     string    `bson:"source" json:"source"`
+	ObservedAt time.Time `bson:"observed_at" json:"observed_at"`
+}
+
+// PriceStats are computed from a product's price history.
+//
+// PercentChange compares the latest price with the last different price
+// observed before it, so a drop from 1,200 to 1,080 is -10.
+type PriceStats struct {
+	AllTimeLow    float64 `bson:"all_time_low" json:"all_time_low"`
+	AllTimeHigh   float64 `bson:"all_time_high" json:"all_time_high"`
+	Average30d    float64 `bson:"average_30d" json:"average_30d"`
+	PercentChange float64 `bson:"percent_change" json:"percent_change"`
+}
+
+// PriceTimeline is a product's price history, oldest first.
+type PriceTimeline struct {
+	ProductID string       `json:"product_id"`
+	Points    []PricePoint `json:"points"`
+	Stats     PriceStats   `json:"stats"`
+}
+
+// Subscription asks to be notified when a product, or any product matching
+// a saved query, is priced at or below Threshold.
+//
+// Notify is "<channel>:<recipient>", e.g. "webhook:https://example.com/hook"
+// or "email:kofi@example.com".
+type Subscription struct {
+	ID        string    `bson:"_id" json:"id"`
+	ProductID string    `bson:"product_id,omitempty" json:"product_id,omitempty"`
+	Query     string    `bson:"query,omitempty" json:"query,omitempty"`
+	Source    string    `bson:"source,omitempty" json:"source,omitempty"`
+	Threshold float64   `bson:"threshold" json:"threshold"`
+	Notify    string    `bson:"notify" json:"notify"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// Alert is what gets delivered to a subscriber when a price drops below their threshold.
+type Alert struct {
+	SubscriptionID string    `json:"subscription_id"`
+	ProductID      string    `json:"product_id"`
+	Name           string    `json:"name"`
+	URL            string    `json:"url"`
+	Source         string    `json:"source"`
+	Price          float64   `json:"price"`
+	Threshold      float64   `json:"threshold"`
+	ObservedAt     time.Time `json:"observed_at"`
+}
+
+// AlertDelivery remembers the last price a subscriber was alerted about for
+// a product, so recrawls at the same or a higher price don't alert again.
+type AlertDelivery struct {
+	ID             string    `bson:"_id"`
+	SubscriptionID string    `bson:"subscription_id"`
+	ProductID      string    `bson:"product_id"`
+	Threshold      float64   `bson:"threshold"`
+	Price          float64   `bson:"price"`
+	DeliveredAt    time.Time `bson:"delivered_at"`
+}
+
+// Offer is one shop's listing of a product within a ProductGroup.
+type Offer struct {
+	ProductID string  `bson:"product_id" json:"product_id"`
+	Source    string  `bson:"source" json:"source"`
+	Name      string  `bson:"name" json:"name"`
+	URL       string  `bson:"url" json:"url"`
+	Price     float64 `bson:"price" json:"price"`
+	Image     string  `bson:"image" json:"image"`
+}
+
+// ProductGroup is a canonical product with the offers of every
+// shop that was found to sell it.
+type ProductGroup struct {
+	ID        string    `bson:"_id" json:"id"`
+	Name      string    `bson:"name" json:"name"`
+	Brand     string    `bson:"brand" json:"brand"`
+	Models    []string  `bson:"models" json:"models"`
+	GTIN      string    `bson:"gtin,omitempty" json:"gtin,omitempty"`
+	Offers    []Offer   `bson:"offers" json:"offers"`
+	Stores    int       `bson:"stores" json:"stores"`
+	MinPrice  float64   `bson:"min_price" json:"min_price"`
+	Summary   string    `bson:"summary" json:"summary"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
 }
 
 type MetaData struct {
@@ -45,13 +262,52 @@ type Data struct {
 	IsArray     bool     `json:"isArray"`
 	ChildAttrib string   `json:"childAttrib"`
 	Selector    Selector `json:"selector"`
+	// Required fields are on every product page; a page without them isn't saved.
+	// When no field is required, "name" and "price" are.
+	Required bool `json:"required"`
+}
+
+// LinkRules are URL patterns used by the sniffer to classify links.
+// A pattern is a glob matched against the path and query, or a regular
+// expression when prefixed with "re:".
+type LinkRules struct {
+	Product []string `json:"product"`
+	Listing []string `json:"listing"`
+	Ignore  []string `json:"ignore"`
+}
+
+// RecrawlPolicy bounds how often a target's products are revisited.
+// Intervals are Go durations, e.g. "6h". Empty means the global default.
+type RecrawlPolicy struct {
+	MinInterval string `json:"min_interval"`
+	MaxInterval string `json:"max_interval"`
 }
 
 type Target struct {
-	Target   string `json:"target"`
-	Host     string `json:"host"`
-	SeedPath string `json:"seed_path"`
-	Data     []Data `json:"data"`
+	Target   string        `json:"target"`
+	Host     string        `json:"host"`
+	SeedPath string        `json:"seed_path"`
+	Data     []Data        `json:"data"`
+	Rules    LinkRules     `json:"rules"`
+	Recrawl  RecrawlPolicy `json:"recrawl"`
+	// Proxies are HTTP or SOCKS5 proxy URLs the target is fetched through,
+	// instead of the default PROXIES pool.
+	Proxies []string `json:"proxies"`
+	// Disabled targets are neither sniffed nor crawled.
+	Disabled bool `json:"disabled"`
+	// Version counts the saved changes to the target's definition.
+	Version int `json:"version"`
+}
+
+// TargetVersion is a saved revision of a target's definition.
+type TargetVersion struct {
+	ID         string    `bson:"_id" json:"-"`
+	Target     string    `bson:"target" json:"target"`
+	Version    int       `bson:"version" json:"version"`
+	Author     string    `bson:"author" json:"author"`
+	Comment    string    `bson:"comment" json:"comment"`
+	SavedAt    time.Time `bson:"saved_at" json:"saved_at"`
+	Definition Target    `bson:"definition" json:"definition"`
 }
 
 type Config struct {
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/config"
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MarkBlocked puts a source into a cooldown after it served a block page.
+// The cooldown doubles with every block in a row; blocks during a cooldown don't count.
+//
+// Parameters:
+// - url: the page we were turned away from.
+// - reason: why the response looked like a block page.
+func (db *Database) MarkBlocked(source, host, url, reason string) (data.BlockState, error) {
+	state, err := db.GetBlockState(source)
+	if err != nil {
+		return state, err
+	}
+
+	now := time.Now()
+
+	// Pages fetched alongside the first blocked one don't stretch the cooldown.
+	if now.Before(state.BlockedUntil) {
+		return state, nil
+	}
+
+	if state.Blocks == 0 {
+		state.Since = now
+	}
+
+	state.Source = source
+	state.Host = host
+	state.URL = url
+	state.Reason = reason
+	state.Blocks++
+
+	cooldown := config.BLOCK_COOLDOWN << min(state.Blocks-1, 6)
+	if cooldown > config.BLOCK_MAX_COOLDOWN {
+		cooldown = config.BLOCK_MAX_COOLDOWN
+	}
+
+	state.BlockedUntil = now.Add(cooldown)
+
+	_, err = db.Collection("block_state").ReplaceOne(context.TODO(), bson.M{"_id": source}, state, options.Replace().SetUpsert(true))
+
+	return state, err
+}
+
+// ClearBlocked ends a source's block streak once it serves a real page again.
+func (db *Database) ClearBlocked(source string) error {
+	_, err := db.Collection("block_state").DeleteOne(context.TODO(), bson.M{"_id": source})
+
+	return err
+}
+
+// GetBlockState retrieves a source's block state. A source that isn't
+// blocked has a zero state.
+func (db *Database) GetBlockState(source string) (data.BlockState, error) {
+	state := data.BlockState{}
+
+	err := db.Collection("block_state").FindOne(context.TODO(), bson.M{"_id": source}).Decode(&state)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return state, nil
+	}
+
+	return state, err
+}
+
+// GetBlockStates retrieves the block state of every source that got blocked.
+func (db *Database) GetBlockStates() ([]data.BlockState, error) {
+	states := []data.BlockState{}
+
+	res, err := db.Collection("block_state").Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return states, err
+	}
+
+	err = res.All(context.TODO(), &states)
+
+	return states, err
+}
@@ -2,60 +2,81 @@ package database
 
 import (
 	"context"
-	"fmt"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"maps"
 	"math/rand"
 	netURL "net/url"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/Cedi-Search/Cedi-Search-Engine/config"
 	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/metrics"
+	"github.com/Cedi-Search/Cedi-Search-Engine/tracing"
 	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
 	"github.com/algolia/algoliasearch-client-go/v3/algolia/search"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Database struct {
 	*mongo.Database
-	AlgoliaIndex *search.Index
+	AlgoliaClient *search.Client
+	AlgoliaIndex  *search.Index
+
+	priceObservers []PriceObserver
 }
 
 // NewDatabase initializes a new instance of the Database struct.
 //
 // Returns a pointer to the newly created Database.
 func NewDatabase() *Database {
-	utils.Logger(utils.Database, utils.Database, "Initing database...")
+	utils.Log(utils.Database).Info("Initing database")
 
 	dbURI := os.Getenv("DB_URI")
 
 	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(dbURI))
 	if err != nil {
-		utils.Logger(utils.Error, utils.Database, err)
+		utils.Log(utils.Database).Error("Failed to connect to database", "error", err)
 	}
 
 	algoliaClient := search.NewClient(os.Getenv("ALGOLIA_APP_ID"), os.Getenv("ALGOLIA_API_KEY"))
 
 	algoliaIndex := algoliaClient.InitIndex("products")
 
-	utils.Logger(utils.Database, utils.Database, "Database initialized!")
+	utils.Log(utils.Database).Info("Database initialized")
 
 	return &Database{
-		AlgoliaIndex: algoliaIndex,
-		Database:     client.Database("cedi_search"),
+		AlgoliaClient: algoliaClient,
+		AlgoliaIndex:  algoliaIndex,
+		Database:      client.Database("cedi_search"),
 	}
 }
 
 // GetQueue retrieves a slice of data.UrlQueue from the Database.
-// It randomly selects 10 URLs from the queue and returns
-// them as a slice of data.UrlQueue.
+// It randomly selects 5 URLs that are due from the queue and returns
+// them as a slice of data.UrlQueue. URLs waiting to be retried aren't due.
 func (db *Database) GetQueue(source string) ([]data.UrlQueue, error) {
-	utils.Logger(utils.Database, utils.Database, "Getting queue for ", source)
+	utils.Log(utils.Database).Debug("Getting queue", "source", source)
 
 	queueCol := db.Collection("url_queues")
 
-	queueCount, err := queueCol.CountDocuments(context.TODO(), bson.D{{Key: "source", Value: source}}, options.Count())
+	filter := bson.M{
+		"source": source,
+		"$or": bson.A{
+			bson.M{"next_attempt_at": bson.M{"$exists": false}},
+			bson.M{"next_attempt_at": bson.M{"$lte": time.Now()}},
+		},
+	}
+
+	queueCount, err := queueCol.CountDocuments(context.TODO(), filter, options.Count())
 	if err != nil {
 		return []data.UrlQueue{}, err
 	}
@@ -68,7 +89,7 @@ func (db *Database) GetQueue(source string) ([]data.UrlQueue, error) {
 
 	res, err := queueCol.Find(
 		context.TODO(),
-		bson.D{{Key: "source", Value: source}},
+		filter,
 		&options.FindOptions{
 			Skip:  options.Count().SetSkip(int64(skipN)).Skip,
 			Limit: options.Count().SetLimit(5).Limit,
@@ -87,7 +108,7 @@ func (db *Database) GetQueue(source string) ([]data.UrlQueue, error) {
 //
 // It takes a parameter 'url' of type `data.UrlQueue` which represents the URL to be added.
 func (db *Database) AddToQueue(url data.UrlQueue) error {
-	utils.Logger(utils.Database, utils.Database, "Adding to queue...", url.URL)
+	utils.Log(utils.Database).Debug("Adding to queue", "source", url.Source, "url", url.URL)
 
 	parsedURL, err := netURL.Parse(url.URL)
 	if err != nil {
@@ -101,24 +122,47 @@ func (db *Database) AddToQueue(url data.UrlQueue) error {
 		return err
 	}
 
-	utils.Logger(utils.Database, utils.Database, "Added to queue!")
+	utils.Log(utils.Database).Debug("Added to queue", "source", url.Source, "url", url.URL)
 
 	return nil
 }
 
+// Enqueue adds a URL to the queue like AddToQueue, starting a trace for its
+// journey through the pipeline. The trace is linked to the span in ctx, e.g.
+// the listing it was sniffed from, and its traceparent is stored with the URL.
+func (db *Database) Enqueue(ctx context.Context, url data.UrlQueue) error {
+	ctx, span := tracing.Start(ctx, "queue.enqueue",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(ctx)),
+		trace.WithAttributes(
+			attribute.String("source", url.Source),
+			attribute.String("url", url.URL),
+			attribute.Bool("recrawl", url.Recrawl),
+		),
+	)
+
+	url.TraceParent = tracing.Inject(ctx)
+
+	err := db.AddToQueue(url)
+
+	tracing.End(span, err)
+
+	return err
+}
+
 // DeleteFromQueue deletes a URL from the queue in the Database.
 //
 // It takes a parameter `url` of type `data.UrlQueue`, which represents the URL to be deleted from the queue.
 // This function does not return any value.
 func (db *Database) DeleteFromQueue(url data.UrlQueue) error {
-	utils.Logger(utils.Database, utils.Database, "Deleting from queue...", url.URL)
+	utils.Log(utils.Database).Debug("Deleting from queue", "source", url.Source, "url", url.URL)
 
-	_, err := db.Collection("url_queues").DeleteOne(context.TODO(), url)
+	_, err := db.Collection("url_queues").DeleteOne(context.TODO(), bson.M{"_id": url.ID})
 	if err != nil {
 		return err
 	}
 
-	utils.Logger(utils.Database, utils.Database, "Deleted from queue")
+	utils.Log(utils.Database).Debug("Deleted from queue", "source", url.Source, "url", url.URL)
 
 	return nil
 }
@@ -137,11 +181,20 @@ func (db *Database) CanQueueUrl(url string) (bool, error) {
 	}
 
 	existsInQueue := db.Collection("url_queues").FindOne(context.TODO(), bson.D{{Key: "_id", Value: parsedURL.Path}}).Err() == nil
-	existsInIndexedProducts := db.Collection("indexed_products").FindOne(context.TODO(), bson.D{{Key: "_id", Value: parsedURL.Path}}) == nil
 
-	canQueue := !existsInQueue && !existsInIndexedProducts
+	productID, err := utils.CanonicalURL(url)
+	if err != nil {
+		return false, err
+	}
+
+	existsInIndexedProducts := db.Collection("indexed_products").FindOne(context.TODO(), bson.D{{Key: "_id", Value: productID}}).Err() == nil
+
+	// Dead letters only come back through "dead-letters requeue".
+	isDeadLetter := db.Collection("dead_letters").FindOne(context.TODO(), bson.D{{Key: "_id", Value: parsedURL.Path}}).Err() == nil
 
-	utils.Logger(utils.Database, utils.Database, fmt.Sprintf("Can queue %s?", url), canQueue)
+	canQueue := !existsInQueue && !existsInIndexedProducts && !isDeadLetter
+
+	utils.Log(utils.Database).Debug("Checked whether URL can be queued", "url", url, "can_queue", canQueue)
 
 	return canQueue, nil
 }
@@ -154,7 +207,7 @@ func (db *Database) CanQueueUrl(url string) (bool, error) {
 // Returns:
 // - an array of data.CrawledPage representing the retrieved crawled pages.
 func (db *Database) GetCrawledPages(source string) ([]data.CrawledPage, error) {
-	utils.Logger(utils.Database, utils.Database, "Getting crawled pages for ", source)
+	utils.Log(utils.Database).Debug("Getting crawled pages", "source", source)
 
 	res, err := db.Collection("crawled_pages").Find(context.TODO(), bson.D{{Key: "source", Value: source}}, &options.FindOptions{Limit: options.Count().SetLimit(5).Limit})
 	if err != nil {
@@ -164,41 +217,210 @@ func (db *Database) GetCrawledPages(source string) ([]data.CrawledPage, error) {
 	var pages []data.CrawledPage
 	res.All(context.TODO(), &pages)
 
-	utils.Logger(utils.Database, utils.Database, "Crawled pages for ", source, " retrieved!")
+	utils.Log(utils.Database).Debug("Crawled pages retrieved", "source", source)
 
 	return pages, nil
 }
 
-// IndexProduct saves a product to the indexed_products collection in the database.
+// SaveCrawledPage records where the archived copy of a crawled page lives.
 //
-// It takes a parameter `product` of type `data.Product`.
+// The HTML is not stored in the database; it is read back from the WARC archive.
+func (db *Database) SaveCrawledPage(page data.CrawledPage) error {
+	utils.Log(utils.Database).Debug("Saving crawled page", "source", page.Source, "url", page.URL)
+
+	page.HTML = ""
+
+	_, err := db.Collection("crawled_pages").InsertOne(context.TODO(), page, &options.InsertOneOptions{})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// IndexProduct upserts a product into the indexed_products collection.
+//
+// Products are keyed by their canonical URL. first_seen is set when a product is
+// first indexed, last_seen on every call, and last_changed together with the
+// content hash whenever the extracted fields differ from what is stored.
+// The search index is only updated when something actually changed.
 func (db *Database) IndexProduct(product map[string]interface{}) error {
-	utils.Logger(utils.Database, utils.Database, "Saving product...", product["name"])
+	return db.indexProduct(product, true)
+}
 
-	parsedURL, err := netURL.Parse(product["url"].(string))
+// ReindexProduct updates a product re-extracted from an archived page. Its price
+// was recorded when the page was crawled, so unlike IndexProduct it adds nothing
+// to the price history and tells no price observer.
+//
+// Removed products are left alone: their archived pages are from before they
+// were removed, and replaying one would bring the product back.
+func (db *Database) ReindexProduct(product map[string]interface{}) error {
+	return db.indexProduct(product, false)
+}
+
+// indexProduct upserts a product. A live product was crawled just now, so its
+// price is recorded as observed now; otherwise it's a replay of an archived page.
+func (db *Database) indexProduct(product map[string]interface{}, live bool) error {
+	utils.Log(utils.Database).Debug("Saving product", "source", product["source"], "url", product["url"], "name", product["name"])
+
+	id, err := utils.CanonicalURL(product["url"].(string))
 	if err != nil {
 		return err
 	}
 
-	segments := strings.Split(parsedURL.Path, "/")
+	parsedURL, err := netURL.Parse(id)
+	if err != nil {
+		return err
+	}
 
-	product["slug"] = segments[len(segments)-1]
+	product["slug"] = slugFromPath(parsedURL.Path)
 
-	_, err = db.Collection("indexed_products").InsertOne(context.TODO(), product, &options.InsertOneOptions{})
+	hash, err := contentHash(product)
 	if err != nil {
 		return err
 	}
 
-	res, err := db.AlgoliaIndex.SaveObject(product)
+	stored := struct {
+		ContentHash     string           `bson:"content_hash"`
+		Price           float64          `bson:"price"`
+		Availability    string           `bson:"availability"`
+		CrawlIntervalMs int64            `bson:"crawl_interval_ms"`
+		PriceStats      *data.PriceStats `bson:"price_stats"`
+	}{}
+
+	err = db.Collection("indexed_products").FindOne(
+		context.TODO(),
+		bson.M{"_id": id},
+		options.FindOne().SetProjection(bson.M{"content_hash": 1, "price": 1, "availability": 1, "crawl_interval_ms": 1, "price_stats": 1}),
+	).Decode(&stored)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	isNew := errors.Is(err, mongo.ErrNoDocuments)
+
+	if !live && stored.Availability == data.Removed {
+		utils.Log(utils.Database).Debug("Product was removed, not reindexing", "product", id)
+		return nil
+	}
+
+	// A product that comes back after being removed is changed even when its content isn't.
+	changed := isNew || stored.ContentHash != hash || stored.Availability == data.Removed
+
+	now := time.Now()
+
+	// What recrawls care about is how often the price or availability moves.
+	price, _ := product["price"].(float64)
+	availability, _ := product["availability"].(string)
+
+	crawlInterval := config.RECRAWL_DEFAULT_INTERVAL
+	if !isNew {
+		volatileChanged := stored.Price != price || stored.Availability != availability
+
+		crawlInterval = NextCrawlInterval(time.Duration(stored.CrawlIntervalMs)*time.Millisecond, volatileChanged)
+	}
+
+	// A replay says nothing about when the product was last seen or how often
+	// it changes, so it leaves the freshness timestamps and crawl interval alone.
+	set := bson.M{}
+
+	if live {
+		set["last_seen"] = now
+		set["crawl_interval_ms"] = crawlInterval.Milliseconds()
+	}
+
+	if changed {
+		if live {
+			set["last_changed"] = now
+		}
+
+		set["content_hash"] = hash
+
+		for key, value := range product {
+			set[key] = value
+		}
+	}
+
+	source, _ := product["source"].(string)
+
+	if len(set) == 0 {
+		utils.Log(utils.Database).Debug("Product unchanged", "source", source, "product", id)
+		return nil
+	}
+
+	update := bson.M{
+		"$set":         set,
+		"$setOnInsert": bson.M{"first_seen": now},
+	}
+
+	_, err = db.Collection("indexed_products").UpdateOne(context.TODO(), bson.M{"_id": id}, update, options.Update().SetUpsert(true))
 	if err != nil {
 		return err
 	}
 
-	res.Wait()
+	if live {
+		switch {
+		case isNew:
+			metrics.IndexedProducts.WithLabelValues(source, "new").Inc()
+		case changed:
+			metrics.IndexedProducts.WithLabelValues(source, "updated").Inc()
+		default:
+			metrics.IndexedProducts.WithLabelValues(source, "unchanged").Inc()
+		}
+	}
 
-	utils.Logger(utils.Database, utils.Database, "Product Saved!")
+	// Replays keep the stats of the recorded history.
+	priceStats := stored.PriceStats
+
+	if price, ok := product["price"].(float64); ok && price > 0 && live {
+		stats, err := db.RecordPrice(id, price, source)
+		if err != nil {
+			return err
+		}
+
+		priceStats = &stats
+
+		db.notifyPriceObservers(product, data.PricePoint{
+			ProductID:  id,
+			Price:      price,
+			Source:     source,
+			ObservedAt: now,
+		})
+	}
 
-	_, err = db.Collection("meta_data").UpdateOne(context.TODO(), bson.M{"_id": "updated_at"}, bson.M{"$set": data.MetaData{UpdatedAt: time.Now().Format(time.RFC3339)}})
+	if !changed {
+		utils.Log(utils.Database).Debug("Product unchanged", "source", source, "product", id)
+		return nil
+	}
+
+	searchObject := map[string]interface{}{"objectID": id}
+	for key, value := range product {
+		searchObject[key] = value
+	}
+
+	if priceStats != nil {
+		searchObject["price_stats"] = priceStats
+	}
+
+	if product["availability"] == data.Removed {
+		res, err := db.AlgoliaIndex.DeleteObject(id)
+		if err != nil {
+			return err
+		}
+
+		res.Wait()
+	} else {
+		res, err := db.AlgoliaIndex.SaveObject(searchObject)
+		if err != nil {
+			return err
+		}
+
+		res.Wait()
+	}
+
+	utils.Log(utils.Database).Info("Product saved", "source", source, "product", id, "new", isNew)
+
+	_, err = db.Collection("meta_data").UpdateOne(context.TODO(), bson.M{"_id": "updated_at"}, bson.M{"$set": data.MetaData{UpdatedAt: now.Format(time.RFC3339)}})
 	if err != nil {
 		return err
 	}
@@ -206,14 +428,88 @@ func (db *Database) IndexProduct(product map[string]interface{}) error {
 	return nil
 }
 
-// GetTargets fetches the targets together with their
+// contentHash hashes the extracted fields of a product so changes can be detected
+// without comparing every field. Map keys are sorted by encoding/json, so the hash is stable.
+//
+// The URL is left out: the product is keyed by its canonical URL already, and the
+// link it was reached through may carry tracking parameters that change nothing.
+func contentHash(product map[string]interface{}) (string, error) {
+	content := maps.Clone(product)
+	delete(content, "url")
+
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(encoded)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// MigrateProductIDs re-keys products stored before products were keyed by their
+// canonical URL, which have ObjectID ids. A legacy product whose canonical URL is
+// already indexed is a duplicate and is deleted.
+//
+// Returns how many products were re-keyed and how many duplicates were deleted.
+func (db *Database) MigrateProductIDs() (int, int, error) {
+	col := db.Collection("indexed_products")
+
+	res, err := col.Find(context.TODO(), bson.M{"_id": bson.M{"$type": "objectId"}})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	legacy := []bson.M{}
+
+	err = res.All(context.TODO(), &legacy)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	migrated, duplicates := 0, 0
+
+	for _, product := range legacy {
+		oldID := product["_id"]
+
+		url, _ := product["url"].(string)
+
+		id, err := utils.CanonicalURL(url)
+		if err != nil {
+			utils.Log(utils.Database).Warn("Can't migrate product without a valid URL", "id", oldID, "url", url, "error", err)
+			continue
+		}
+
+		product["_id"] = id
+
+		_, err = col.InsertOne(context.TODO(), product)
+
+		switch {
+		case mongo.IsDuplicateKeyError(err):
+			duplicates++
+		case err != nil:
+			return migrated, duplicates, err
+		default:
+			migrated++
+		}
+
+		_, err = col.DeleteOne(context.TODO(), bson.M{"_id": oldID})
+		if err != nil {
+			return migrated, duplicates, err
+		}
+	}
+
+	return migrated, duplicates, nil
+}
+
+// GetTargets fetches the enabled targets together with their
 // selectors to be crawled.
 func (db *Database) GetTargets() ([]data.Target, error) {
-	utils.Logger(utils.Database, utils.Database, "Getting targets")
+	utils.Log(utils.Database).Debug("Getting targets")
 
 	targets := []data.Target{}
 
-	res, err := db.Collection("targets").Find(context.TODO(), bson.D{}, &options.FindOptions{})
+	res, err := db.Collection("targets").Find(context.TODO(), bson.M{"disabled": bson.M{"$ne": true}}, &options.FindOptions{})
 	if err != nil {
 		return targets, err
 	}
@@ -222,3 +518,147 @@ func (db *Database) GetTargets() ([]data.Target, error) {
 
 	return targets, nil
 }
+
+// GetSniffedPage retrieves a listing page from the sniffer's visited set.
+//
+// Parameters:
+// - source: the target the page belongs to. e.g. Jumia
+// - path: the path and query of the page.
+//
+// Returns mongo.ErrNoDocuments if the page has never been sniffed.
+func (db *Database) GetSniffedPage(source, path string) (data.SniffedPage, error) {
+	page := data.SniffedPage{}
+
+	err := db.Collection("sniffed_pages").FindOne(context.TODO(), bson.M{"_id": source + path}).Decode(&page)
+
+	return page, err
+}
+
+// MarkSniffed adds a listing page to the sniffer's visited set,
+// replacing any earlier visit of the same page.
+func (db *Database) MarkSniffed(page data.SniffedPage) error {
+	page.ID = page.Source + page.Path
+
+	_, err := db.Collection("sniffed_pages").ReplaceOne(context.TODO(), bson.M{"_id": page.ID}, page, options.Replace().SetUpsert(true))
+
+	return err
+}
+
+// slugFromPath returns the last segment of a URL path.
+func slugFromPath(path string) string {
+	segments := strings.Split(path, "/")
+
+	return segments[len(segments)-1]
+}
+
+// FindCrawledPages retrieves the latest crawled page of every URL
+// matching a source and/or a URL pattern.
+//
+// Parameters:
+// - source: the source of the crawled pages. e.g. Jumia. Empty matches all sources.
+// - urlPattern: a regular expression the page URL must match. Empty matches all URLs.
+func (db *Database) FindCrawledPages(source, urlPattern string) ([]data.CrawledPage, error) {
+	utils.Log(utils.Database).Debug("Finding crawled pages", "source", source, "url_pattern", urlPattern)
+
+	filter := bson.M{}
+
+	if source != "" {
+		filter["source"] = source
+	}
+
+	if urlPattern != "" {
+		filter["url"] = bson.M{"$regex": urlPattern}
+	}
+
+	res, err := db.Collection("crawled_pages").Find(context.TODO(), filter, options.Find().SetSort(bson.D{{Key: "fetched_at", Value: -1}}))
+	if err != nil {
+		return []data.CrawledPage{}, err
+	}
+
+	var pages []data.CrawledPage
+
+	err = res.All(context.TODO(), &pages)
+	if err != nil {
+		return []data.CrawledPage{}, err
+	}
+
+	latest := []data.CrawledPage{}
+	seen := map[string]bool{}
+
+	for _, page := range pages {
+		if seen[page.URL] {
+			continue
+		}
+
+		seen[page.URL] = true
+		latest = append(latest, page)
+	}
+
+	return latest, nil
+}
+
+// GetProduct retrieves an indexed product by its URL.
+//
+// Returns mongo.ErrNoDocuments if the product has not been indexed.
+func (db *Database) GetProduct(url string) (bson.M, error) {
+	product := bson.M{}
+
+	id, err := utils.CanonicalURL(url)
+	if err != nil {
+		return product, err
+	}
+
+	err = db.Collection("indexed_products").FindOne(context.TODO(), bson.M{"_id": id}).Decode(&product)
+
+	return product, err
+}
+
+// MarkProductRemoved marks an indexed product as removed from its shop and
+// drops it from the search index. Products that were never indexed are ignored.
+// For products that were removed already it only records that they were checked,
+// so their next recrawl is a full interval away.
+func (db *Database) MarkProductRemoved(url string) error {
+	utils.Log(utils.Database).Info("Marking product as removed", "url", url)
+
+	id, err := utils.CanonicalURL(url)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	res, err := db.Collection("indexed_products").UpdateOne(
+		context.TODO(),
+		bson.M{"_id": id, "availability": bson.M{"$ne": data.Removed}},
+		bson.M{
+			"$set": bson.M{
+				"availability":      data.Removed,
+				"availability_rank": 0,
+				"last_seen":         now,
+				"last_changed":      now,
+			},
+			// Forget the content, so the product counts as changed when it comes back.
+			"$unset": bson.M{"content_hash": ""},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.ModifiedCount == 0 {
+		_, err = db.Collection("indexed_products").UpdateOne(
+			context.TODO(),
+			bson.M{"_id": id, "availability": data.Removed},
+			bson.M{"$set": bson.M{"last_seen": now}},
+		)
+
+		return err
+	}
+
+	deleteRes, err := db.AlgoliaIndex.DeleteObject(id)
+	if err != nil {
+		return err
+	}
+
+	return deleteRes.Wait()
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/config"
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RetryDelay is how long a URL waits before it's crawled again after failing `attempts` times.
+func RetryDelay(attempts int) time.Duration {
+	delay := config.QUEUE_RETRY_BASE << min(max(attempts-1, 0), 10)
+	if delay > config.QUEUE_RETRY_MAX {
+		return config.QUEUE_RETRY_MAX
+	}
+
+	return delay
+}
+
+// FailQueued records a failed crawl of a queued URL and schedules its retry.
+// After config.QUEUE_MAX_ATTEMPTS failures the URL is moved to the dead letters instead.
+//
+// It returns the updated queue entry and whether it was dead-lettered.
+func (db *Database) FailQueued(url data.UrlQueue, cause error) (data.UrlQueue, bool, error) {
+	url.Attempts++
+	url.LastError = cause.Error()
+	url.NextAttemptAt = time.Now().Add(RetryDelay(url.Attempts))
+
+	if url.Attempts >= config.QUEUE_MAX_ATTEMPTS {
+		utils.Log(utils.Database).Warn("Dead-lettering URL", "source", url.Source, "url", url.URL, "attempt", url.Attempts)
+
+		letter := data.DeadLetter{UrlQueue: url, DiedAt: time.Now()}
+		letter.NextAttemptAt = time.Time{}
+
+		_, err := db.Collection("dead_letters").ReplaceOne(context.TODO(), bson.M{"_id": url.ID}, letter, options.Replace().SetUpsert(true))
+		if err != nil {
+			return url, false, err
+		}
+
+		return url, true, db.DeleteFromQueue(url)
+	}
+
+	update := bson.M{"$set": bson.M{
+		"attempts":        url.Attempts,
+		"last_error":      url.LastError,
+		"next_attempt_at": url.NextAttemptAt,
+	}}
+
+	_, err := db.Collection("url_queues").UpdateByID(context.TODO(), url.ID, update)
+
+	return url, false, err
+}
+
+// EnsureDeadLetterIndexes indexes dead letters by URL, which recrawl scheduling
+// looks them up by.
+func (db *Database) EnsureDeadLetterIndexes() error {
+	_, err := db.Collection("dead_letters").Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "url", Value: 1}},
+	})
+
+	return err
+}
+
+// GetDeadLetters retrieves the latest dead letters, optionally of a single source.
+func (db *Database) GetDeadLetters(source string, limit int64) ([]data.DeadLetter, error) {
+	letters := []data.DeadLetter{}
+
+	filter := bson.M{}
+	if source != "" {
+		filter["source"] = source
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "died_at", Value: -1}}).SetLimit(limit)
+
+	res, err := db.Collection("dead_letters").Find(context.TODO(), filter, opts)
+	if err != nil {
+		return letters, err
+	}
+
+	err = res.All(context.TODO(), &letters)
+
+	return letters, err
+}
+
+// GetDeadLetter retrieves the dead letter of a URL.
+func (db *Database) GetDeadLetter(url string) (data.DeadLetter, error) {
+	letter := data.DeadLetter{}
+
+	err := db.Collection("dead_letters").FindOne(context.TODO(), bson.M{"url": url}).Decode(&letter)
+
+	return letter, err
+}
+
+// RequeueDeadLetter puts a dead letter back on the queue with a clean slate.
+func (db *Database) RequeueDeadLetter(letter data.DeadLetter) error {
+	url := letter.UrlQueue
+
+	url.Attempts = 0
+	url.LastError = ""
+	url.NextAttemptAt = time.Time{}
+
+	_, err := db.Collection("url_queues").InsertOne(context.TODO(), url)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("requeue %s: %w", url.URL, err)
+	}
+
+	_, err = db.Collection("dead_letters").DeleteOne(context.TODO(), bson.M{"_id": letter.ID})
+
+	return err
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetAllProducts retrieves every indexed product that hasn't been removed from its shop.
+// Products stored before they were keyed by canonical URL, with ObjectID ids, are left out.
+func (db *Database) GetAllProducts() ([]map[string]interface{}, error) {
+	utils.Log(utils.Database).Debug("Getting all products")
+
+	products := []map[string]interface{}{}
+
+	res, err := db.Collection("indexed_products").Find(context.TODO(), bson.M{"_id": bson.M{"$type": "string"}, "availability": bson.M{"$ne": data.Removed}})
+	if err != nil {
+		return products, err
+	}
+
+	err = res.All(context.TODO(), &products)
+
+	return products, err
+}
+
+// SetImageHash stores the hash of a product's first image.
+func (db *Database) SetImageHash(productID, hash string) error {
+	_, err := db.Collection("indexed_products").UpdateOne(context.TODO(), bson.M{"_id": productID}, bson.M{"$set": bson.M{"image_hash": hash}})
+
+	return err
+}
+
+// SaveProductGroups replaces the stored product groups with `groups`
+// and links every indexed product, in the database and the search index,
+// to the group it belongs to.
+//
+// Groups that are the same as the stored ones are left alone, and so are
+// their products, so a run that changed nothing writes nothing.
+func (db *Database) SaveProductGroups(groups []data.ProductGroup) error {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	stored, err := db.getProductGroups()
+	if err != nil {
+		return err
+	}
+
+	groupWrites := []mongo.WriteModel{}
+	productWrites := []mongo.WriteModel{}
+	searchObjects := []map[string]interface{}{}
+	groupIDs := bson.A{}
+
+	for _, group := range groups {
+		groupIDs = append(groupIDs, group.ID)
+
+		if previous, found := stored[group.ID]; found && sameGroup(previous, group) {
+			continue
+		}
+
+		groupWrites = append(groupWrites, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": group.ID}).
+			SetReplacement(group).
+			SetUpsert(true))
+
+		for _, offer := range group.Offers {
+			fields := bson.M{
+				"group_id":        group.ID,
+				"group_stores":    group.Stores,
+				"group_min_price": group.MinPrice,
+				"group_summary":   group.Summary,
+			}
+
+			productWrites = append(productWrites, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": offer.ProductID}).
+				SetUpdate(bson.M{"$set": fields}))
+
+			searchObject := map[string]interface{}{"objectID": offer.ProductID}
+			for key, value := range fields {
+				searchObject[key] = value
+			}
+
+			searchObjects = append(searchObjects, searchObject)
+		}
+	}
+
+	utils.Log(utils.Database).Info("Saving product groups", "groups", len(groups), "changed", len(groupWrites))
+
+	_, err = db.Collection("product_groups").DeleteMany(context.TODO(), bson.M{"_id": bson.M{"$nin": groupIDs}})
+	if err != nil {
+		return err
+	}
+
+	if len(groupWrites) == 0 {
+		return nil
+	}
+
+	_, err = db.Collection("product_groups").BulkWrite(context.TODO(), groupWrites, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("indexed_products").BulkWrite(context.TODO(), productWrites, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return err
+	}
+
+	res, err := db.AlgoliaIndex.PartialUpdateObjects(searchObjects)
+	if err != nil {
+		return err
+	}
+
+	return res.Wait()
+}
+
+// getProductGroups retrieves the stored product groups by ID.
+func (db *Database) getProductGroups() (map[string]data.ProductGroup, error) {
+	groups := map[string]data.ProductGroup{}
+
+	res, err := db.Collection("product_groups").Find(context.TODO(), bson.M{})
+	if err != nil {
+		return groups, err
+	}
+
+	stored := []data.ProductGroup{}
+
+	err = res.All(context.TODO(), &stored)
+	if err != nil {
+		return groups, err
+	}
+
+	for _, group := range stored {
+		groups[group.ID] = group
+	}
+
+	return groups, nil
+}
+
+// sameGroup reports whether two versions of a group differ in nothing but when they were built.
+func sameGroup(a, b data.ProductGroup) bool {
+	a.UpdatedAt, b.UpdatedAt = time.Time{}, time.Time{}
+
+	left, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+
+	right, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+
+	return string(left) == string(right)
+}
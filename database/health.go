@@ -0,0 +1,38 @@
+package database
+
+import (
+	"context"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SaveFieldHealth stores the latest health check of a source's field.
+func (db *Database) SaveFieldHealth(health data.FieldHealth) error {
+	health.ID = health.Source + "/" + health.Field
+
+	_, err := db.Collection("extraction_health").ReplaceOne(context.TODO(), bson.M{"_id": health.ID}, health, options.Replace().SetUpsert(true))
+
+	return err
+}
+
+// GetFieldHealth retrieves the latest health check of every field, optionally
+// only those of a source.
+func (db *Database) GetFieldHealth(source string) ([]data.FieldHealth, error) {
+	health := []data.FieldHealth{}
+
+	filter := bson.M{}
+	if source != "" {
+		filter["source"] = source
+	}
+
+	res, err := db.Collection("extraction_health").Find(context.TODO(), filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return health, err
+	}
+
+	err = res.All(context.TODO(), &health)
+
+	return health, err
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RecordPrice adds an observed price to a product's history and
+// stores the recomputed price stats on the indexed product.
+//
+// Parameters:
+// - productID: the canonical URL of the product.
+// - price: the observed price.
+// - source: the shop the price was observed on. e.g. Jumia
+//
+// Returns the recomputed stats.
+func (db *Database) RecordPrice(productID string, price float64, source string) (data.PriceStats, error) {
+	utils.Log(utils.Database).Debug("Recording price", "product", productID, "price", price)
+
+	point := data.PricePoint{
+		ProductID:  productID,
+		Price:      price,
+		Source:     source,
+		ObservedAt: time.Now(),
+	}
+
+	_, err := db.Collection("price_history").InsertOne(context.TODO(), point)
+	if err != nil {
+		return data.PriceStats{}, err
+	}
+
+	timeline, err := db.GetPriceTimeline(productID)
+	if err != nil {
+		return data.PriceStats{}, err
+	}
+
+	_, err = db.Collection("indexed_products").UpdateOne(context.TODO(), bson.M{"_id": productID}, bson.M{"$set": bson.M{"price_stats": timeline.Stats}})
+	if err != nil {
+		return timeline.Stats, err
+	}
+
+	return timeline.Stats, nil
+}
+
+// GetPriceTimeline retrieves every observed price of a product, oldest first,
+// together with its computed price stats.
+//
+// Parameters:
+// - url: the URL of the product. It doesn't have to be canonical.
+func (db *Database) GetPriceTimeline(url string) (data.PriceTimeline, error) {
+	productID, err := utils.CanonicalURL(url)
+	if err != nil {
+		return data.PriceTimeline{}, err
+	}
+
+	timeline := data.PriceTimeline{
+		ProductID: productID,
+		Points:    []data.PricePoint{},
+	}
+
+	res, err := db.Collection("price_history").Find(
+		context.TODO(),
+		bson.M{"product_id": productID},
+		options.Find().SetSort(bson.D{{Key: "observed_at", Value: 1}}),
+	)
+	if err != nil {
+		return timeline, err
+	}
+
+	err = res.All(context.TODO(), &timeline.Points)
+	if err != nil {
+		return timeline, err
+	}
+
+	timeline.Stats = ComputePriceStats(timeline.Points, time.Now())
+
+	return timeline, nil
+}
+
+// ComputePriceStats computes price stats from a price history sorted oldest first.
+//
+// The 30 day average is the mean of the prices observed in the 30 days before `now`,
+// or the latest price if there were none.
+func ComputePriceStats(points []data.PricePoint, now time.Time) data.PriceStats {
+	stats := data.PriceStats{}
+
+	if len(points) == 0 {
+		return stats
+	}
+
+	stats.AllTimeLow = points[0].Price
+	stats.AllTimeHigh = points[0].Price
+
+	windowStart := now.AddDate(0, 0, -30)
+	windowTotal, windowCount := 0.0, 0
+
+	for _, point := range points {
+		if point.Price < stats.AllTimeLow {
+			stats.AllTimeLow = point.Price
+		}
+
+		if point.Price > stats.AllTimeHigh {
+			stats.AllTimeHigh = point.Price
+		}
+
+		if !point.ObservedAt.Before(windowStart) {
+			windowTotal += point.Price
+			windowCount++
+		}
+	}
+
+	latest := points[len(points)-1].Price
+
+	stats.Average30d = latest
+	if windowCount > 0 {
+		stats.Average30d = windowTotal / float64(windowCount)
+	}
+
+	for i := len(points) - 2; i >= 0; i-- {
+		previous := points[i].Price
+
+		if previous != latest {
+			if previous != 0 {
+				stats.PercentChange = (latest - previous) / previous * 100
+			}
+
+			break
+		}
+	}
+
+	return stats
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RecordProxyStats adds fetch counts to the running totals of their proxies.
+func (db *Database) RecordProxyStats(stats []data.ProxyStats) error {
+	for _, stat := range stats {
+		inc := bson.M{
+			"requests":  stat.Requests,
+			"successes": stat.Successes,
+			"failures":  stat.Failures,
+			"blocks":    stat.Blocks,
+		}
+
+		_, err := db.Collection("proxy_stats").UpdateOne(context.TODO(), bson.M{"_id": stat.Proxy}, bson.M{"$inc": inc}, options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetProxyStats retrieves the fetch counts of every proxy.
+func (db *Database) GetProxyStats() ([]data.ProxyStats, error) {
+	stats := []data.ProxyStats{}
+
+	res, err := db.Collection("proxy_stats").Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return stats, err
+	}
+
+	err = res.All(context.TODO(), &stats)
+
+	return stats, err
+}
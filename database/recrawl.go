@@ -0,0 +1,169 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/config"
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NextCrawlInterval adapts how long to wait before revisiting a product.
+//
+// The interval halves when the price or availability changed since the last
+// crawl and grows by half when it didn't, within config.RECRAWL_MIN_INTERVAL
+// and config.RECRAWL_MAX_INTERVAL. Per-source bounds are applied when
+// products are picked for recrawling.
+func NextCrawlInterval(previous time.Duration, changed bool) time.Duration {
+	if previous <= 0 {
+		previous = config.RECRAWL_DEFAULT_INTERVAL
+	}
+
+	next := previous * 3 / 2
+	if changed {
+		next = previous / 2
+	}
+
+	return min(max(next, config.RECRAWL_MIN_INTERVAL), config.RECRAWL_MAX_INTERVAL)
+}
+
+// GetDueProducts retrieves the URLs of a source's products that are due for a recrawl:
+// those last seen longer ago than their crawl interval, clamped to [minInterval, maxInterval].
+// The most overdue come first. Dead-lettered URLs are left out until they're requeued.
+//
+// Removed products are checked every maxInterval, so they're picked up again
+// when the shop lists them again.
+func (db *Database) GetDueProducts(source string, minInterval, maxInterval time.Duration, limit int) ([]string, error) {
+	now := time.Now()
+
+	interval := bson.M{"$min": bson.A{
+		bson.M{"$max": bson.A{bson.M{"$ifNull": bson.A{"$crawl_interval_ms", config.RECRAWL_DEFAULT_INTERVAL.Milliseconds()}}, minInterval.Milliseconds()}},
+		maxInterval.Milliseconds(),
+	}}
+
+	interval = bson.M{"$cond": bson.A{
+		bson.M{"$eq": bson.A{"$availability", data.Removed}},
+		maxInterval.Milliseconds(),
+		interval,
+	}}
+
+	// Products indexed before last_seen was tracked are due straight away.
+	lastSeen := bson.M{"$ifNull": bson.A{"$last_seen", time.Unix(0, 0)}}
+
+	dueAt := bson.M{"$add": bson.A{lastSeen, interval}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"source": source}}},
+		{{Key: "$addFields", Value: bson.M{"due_at": dueAt}}},
+		{{Key: "$match", Value: bson.M{"due_at": bson.M{"$lte": now}}}},
+		{{Key: "$lookup", Value: bson.M{"from": "dead_letters", "localField": "url", "foreignField": "url", "as": "dead_letters"}}},
+		{{Key: "$match", Value: bson.M{"dead_letters": bson.M{"$size": 0}}}},
+		{{Key: "$sort", Value: bson.M{"due_at": 1}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$project", Value: bson.M{"url": 1}}},
+	}
+
+	res, err := db.Collection("indexed_products").Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return []string{}, err
+	}
+
+	var due []struct {
+		URL string `bson:"url"`
+	}
+
+	err = res.All(context.TODO(), &due)
+	if err != nil {
+		return []string{}, err
+	}
+
+	urls := []string{}
+	for _, product := range due {
+		urls = append(urls, product.URL)
+	}
+
+	return urls, nil
+}
+
+// CountQueue counts the URLs queued for a source.
+func (db *Database) CountQueue(source string) (int64, error) {
+	return db.Collection("url_queues").CountDocuments(context.TODO(), bson.M{"source": source})
+}
+
+// GetLatestCrawledPage retrieves the most recent crawl of a URL.
+//
+// Returns mongo.ErrNoDocuments if the URL was never crawled.
+func (db *Database) GetLatestCrawledPage(url string) (data.CrawledPage, error) {
+	page := data.CrawledPage{}
+
+	err := db.Collection("crawled_pages").FindOne(
+		context.TODO(),
+		bson.M{"url": url},
+		options.FindOne().SetSort(bson.D{{Key: "fetched_at", Value: -1}}),
+	).Decode(&page)
+
+	return page, err
+}
+
+// TouchProduct records that a product was seen unchanged, e.g. after a 304,
+// bumping last_seen and growing its crawl interval without re-extracting it.
+func (db *Database) TouchProduct(url string) error {
+	id, err := utils.CanonicalURL(url)
+	if err != nil {
+		return err
+	}
+
+	stored := struct {
+		CrawlIntervalMs int64 `bson:"crawl_interval_ms"`
+	}{}
+
+	err = db.Collection("indexed_products").FindOne(context.TODO(), bson.M{"_id": id}).Decode(&stored)
+	if err != nil {
+		return err
+	}
+
+	interval := NextCrawlInterval(time.Duration(stored.CrawlIntervalMs)*time.Millisecond, false)
+
+	_, err = db.Collection("indexed_products").UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"last_seen":         time.Now(),
+		"crawl_interval_ms": interval.Milliseconds(),
+	}})
+
+	return err
+}
+
+// RecordRevalidation counts a conditional recrawl of a source.
+//
+// Parameters:
+// - notModified: whether the shop answered 304 Not Modified.
+// - bytesSaved: the size of the page we didn't have to download again.
+func (db *Database) RecordRevalidation(source string, notModified bool, bytesSaved int) error {
+	inc := bson.M{"requests": 1}
+
+	if notModified {
+		inc["not_modified"] = 1
+		inc["bytes_saved"] = bytesSaved
+	}
+
+	_, err := db.Collection("revalidation_stats").UpdateOne(context.TODO(), bson.M{"_id": source}, bson.M{"$inc": inc}, options.Update().SetUpsert(true))
+
+	return err
+}
+
+// GetRevalidationStats retrieves the conditional recrawl counts of every source.
+func (db *Database) GetRevalidationStats() ([]data.RevalidationStats, error) {
+	stats := []data.RevalidationStats{}
+
+	res, err := db.Collection("revalidation_stats").Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return stats, err
+	}
+
+	err = res.All(context.TODO(), &stats)
+
+	return stats, err
+}
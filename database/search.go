@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
+	"github.com/algolia/algoliasearch-client-go/v3/algolia/opt"
+	"github.com/algolia/algoliasearch-client-go/v3/algolia/search"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DiscountReplica is the search index replica that ranks products by biggest discount first.
+const DiscountReplica = "products_discount_desc"
+
+// ConfigureSearchIndex applies the settings search relies on to the products index:
+// filtering on source, promotions, availability and discount, demoting products
+// that can't be bought, and a replica sorted by biggest discount.
+func (db *Database) ConfigureSearchIndex() error {
+	utils.Log(utils.Database).Info("Configuring search index")
+
+	res, err := db.AlgoliaIndex.SetSettings(search.Settings{
+		AttributesForFaceting: opt.AttributesForFaceting("source", "filterOnly(promotions)", "filterOnly(availability)"),
+		CustomRanking:         opt.CustomRanking("desc(availability_rank)"),
+		Replicas:              opt.Replicas(DiscountReplica),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = res.Wait()
+	if err != nil {
+		return err
+	}
+
+	res, err = db.AlgoliaClient.InitIndex(DiscountReplica).SetSettings(search.Settings{
+		Ranking:       opt.Ranking("desc(discount_percent)", "typo", "geo", "words", "filters", "proximity", "attribute", "exact", "custom"),
+		CustomRanking: opt.CustomRanking("desc(availability_rank)"),
+	})
+	if err != nil {
+		return err
+	}
+
+	return res.Wait()
+}
+
+// GetTopDiscounts retrieves the products with the biggest discounts
+// that can still be bought.
+//
+// Parameters:
+// - source: only consider products of this source. Empty means every source.
+// - minDiscount: the smallest discount percentage to include.
+// - limit: the maximum number of products to return.
+func (db *Database) GetTopDiscounts(source string, minDiscount float64, limit int64) ([]data.Product, error) {
+	products := []data.Product{}
+
+	filter := bson.M{
+		"discount_percent": bson.M{"$gte": minDiscount, "$gt": 0},
+		"availability":     bson.M{"$nin": bson.A{data.OutOfStock, data.Removed}},
+	}
+
+	if source != "" {
+		filter["source"] = source
+	}
+
+	res, err := db.Collection("indexed_products").Find(
+		context.TODO(),
+		filter,
+		options.Find().SetSort(bson.D{{Key: "discount_percent", Value: -1}}).SetLimit(limit),
+	)
+	if err != nil {
+		return products, err
+	}
+
+	err = res.All(context.TODO(), &products)
+
+	return products, err
+}
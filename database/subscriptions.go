@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PriceObserver is told about every price IndexProduct records.
+type PriceObserver interface {
+	ObservePrice(product map[string]interface{}, point data.PricePoint)
+}
+
+// AddPriceObserver registers an observer to be called, in its own
+// goroutine, whenever a product price is recorded.
+func (db *Database) AddPriceObserver(observer PriceObserver) {
+	db.priceObservers = append(db.priceObservers, observer)
+}
+
+// notifyPriceObservers hands a recorded price to every registered observer,
+// each with its own copy of the product.
+func (db *Database) notifyPriceObservers(product map[string]interface{}, point data.PricePoint) {
+	for _, observer := range db.priceObservers {
+		go observer.ObservePrice(maps.Clone(product), point)
+	}
+}
+
+// AddSubscription saves a price alert subscription.
+//
+// The product URL, if any, is canonicalised so it matches indexed products.
+// Returns the saved subscription with its generated ID.
+func (db *Database) AddSubscription(sub data.Subscription) (data.Subscription, error) {
+	utils.Log(utils.Database).Info("Adding subscription", "notify", sub.Notify)
+
+	if sub.ProductID != "" {
+		productID, err := utils.CanonicalURL(sub.ProductID)
+		if err != nil {
+			return sub, err
+		}
+
+		sub.ProductID = productID
+	}
+
+	sub.ID = uuid.New().String()
+	sub.CreatedAt = time.Now()
+
+	_, err := db.Collection("subscriptions").InsertOne(context.TODO(), sub)
+	if err != nil {
+		return sub, err
+	}
+
+	return sub, nil
+}
+
+// DeleteSubscription deletes a subscription together with its delivery records.
+func (db *Database) DeleteSubscription(id string) error {
+	utils.Log(utils.Database).Info("Deleting subscription", "subscription", id)
+
+	_, err := db.Collection("subscriptions").DeleteOne(context.TODO(), bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("alert_deliveries").DeleteMany(context.TODO(), bson.M{"subscription_id": id})
+
+	return err
+}
+
+// GetSubscriptions retrieves every subscription.
+func (db *Database) GetSubscriptions() ([]data.Subscription, error) {
+	subs := []data.Subscription{}
+
+	res, err := db.Collection("subscriptions").Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return subs, err
+	}
+
+	err = res.All(context.TODO(), &subs)
+
+	return subs, err
+}
+
+// FindTriggeredSubscriptions retrieves the subscriptions whose threshold a price satisfies:
+// those for the product itself and every saved query subscription. Queries are not
+// matched here; callers match them against the product.
+func (db *Database) FindTriggeredSubscriptions(productID string, price float64) ([]data.Subscription, error) {
+	subs := []data.Subscription{}
+
+	filter := bson.M{
+		"threshold": bson.M{"$gte": price},
+		"$or": bson.A{
+			bson.M{"product_id": productID},
+			bson.M{"query": bson.M{"$exists": true, "$ne": ""}},
+		},
+	}
+
+	res, err := db.Collection("subscriptions").Find(context.TODO(), filter)
+	if err != nil {
+		return subs, err
+	}
+
+	err = res.All(context.TODO(), &subs)
+
+	return subs, err
+}
+
+// GetAlertDelivery retrieves the last alert delivered to a subscription for a product.
+//
+// Returns mongo.ErrNoDocuments if none was delivered since the price last went above the threshold.
+func (db *Database) GetAlertDelivery(subscriptionID, productID string) (data.AlertDelivery, error) {
+	delivery := data.AlertDelivery{}
+
+	err := db.Collection("alert_deliveries").FindOne(context.TODO(), bson.M{"_id": subscriptionID + "|" + productID}).Decode(&delivery)
+
+	return delivery, err
+}
+
+// SaveAlertDelivery records that an alert was delivered.
+func (db *Database) SaveAlertDelivery(delivery data.AlertDelivery) error {
+	delivery.ID = delivery.SubscriptionID + "|" + delivery.ProductID
+
+	_, err := db.Collection("alert_deliveries").ReplaceOne(context.TODO(), bson.M{"_id": delivery.ID}, delivery, options.Replace().SetUpsert(true))
+
+	return err
+}
+
+// ClaimAlertDelivery records an alert about to be delivered, unless one was
+// already delivered for the product at the same or a lower price.
+//
+// The check and the write are a single upsert, so of concurrent claims for
+// the same price only one succeeds.
+func (db *Database) ClaimAlertDelivery(delivery data.AlertDelivery) (bool, error) {
+	delivery.ID = delivery.SubscriptionID + "|" + delivery.ProductID
+
+	_, err := db.Collection("alert_deliveries").ReplaceOne(
+		context.TODO(),
+		bson.M{"_id": delivery.ID, "price": bson.M{"$gt": delivery.Price}},
+		delivery,
+		options.Replace().SetUpsert(true),
+	)
+
+	// The delivery exists at a price the filter excluded, so the upsert tried to insert it again.
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// DeleteAlertDelivery forgets the alert delivered to a subscription for a product.
+func (db *Database) DeleteAlertDelivery(subscriptionID, productID string) error {
+	_, err := db.Collection("alert_deliveries").DeleteOne(context.TODO(), bson.M{"_id": subscriptionID + "|" + productID})
+
+	return err
+}
+
+// RearmAlerts forgets deliveries for a product whose threshold the price is now above,
+// so subscribers are alerted again the next time it drops.
+func (db *Database) RearmAlerts(productID string, price float64) error {
+	_, err := db.Collection("alert_deliveries").DeleteMany(context.TODO(), bson.M{
+		"product_id": productID,
+		"threshold":  bson.M{"$lt": price},
+	})
+
+	return err
+}
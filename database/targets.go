@@ -0,0 +1,203 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrTargetNotFound is returned for targets that don't exist.
+var ErrTargetNotFound = errors.New("target not found")
+
+// ErrTargetExists is returned when creating a target that already exists.
+var ErrTargetExists = errors.New("target already exists")
+
+// ErrTargetChanged is returned when a target was changed since the version an update was based on.
+var ErrTargetChanged = errors.New("target was changed by someone else, reload it and try again")
+
+// GetAllTargets fetches every target, including disabled ones.
+func (db *Database) GetAllTargets() ([]data.Target, error) {
+	targets := []data.Target{}
+
+	res, err := db.Collection("targets").Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.D{{Key: "target", Value: 1}}))
+	if err != nil {
+		return targets, err
+	}
+
+	err = res.All(context.TODO(), &targets)
+
+	return targets, err
+}
+
+// GetTarget fetches a target by name, e.g. Jumia.
+func (db *Database) GetTarget(name string) (data.Target, error) {
+	target := data.Target{}
+
+	err := db.Collection("targets").FindOne(context.TODO(), bson.M{"target": name}).Decode(&target)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return target, ErrTargetNotFound
+	}
+
+	return target, err
+}
+
+// CreateTarget saves a new target as version 1.
+func (db *Database) CreateTarget(target data.Target, author, comment string) (data.Target, error) {
+	_, err := db.GetTarget(target.Target)
+	if err == nil {
+		return target, ErrTargetExists
+	}
+
+	if !errors.Is(err, ErrTargetNotFound) {
+		return target, err
+	}
+
+	return db.saveTarget(target, nil, author, comment)
+}
+
+// UpdateTarget saves a new version of an existing target and records it in the history.
+// When the target carries a version, it must be the current one.
+func (db *Database) UpdateTarget(target data.Target, author, comment string) (data.Target, error) {
+	current, err := db.GetTarget(target.Target)
+	if err != nil {
+		return target, err
+	}
+
+	if target.Version > 0 && target.Version != current.Version {
+		return target, ErrTargetChanged
+	}
+
+	return db.saveTarget(target, &current, author, comment)
+}
+
+// SetTargetDisabled enables or disables a target. It's a change like any other, so it gets a version.
+func (db *Database) SetTargetDisabled(name string, disabled bool, author string) (data.Target, error) {
+	current, err := db.GetTarget(name)
+	if err != nil {
+		return current, err
+	}
+
+	target := current
+	target.Disabled = disabled
+
+	comment := "enabled"
+	if disabled {
+		comment = "disabled"
+	}
+
+	return db.saveTarget(target, &current, author, comment)
+}
+
+// RollbackTarget makes an earlier version of a target current again, as a new version.
+func (db *Database) RollbackTarget(name string, version int, author string) (data.Target, error) {
+	old, err := db.GetTargetVersion(name, version)
+	if err != nil {
+		return data.Target{}, err
+	}
+
+	rollback := old.Definition
+	rollback.Version = 0
+
+	return db.UpdateTarget(rollback, author, fmt.Sprintf("rollback to version %d", version))
+}
+
+// saveTarget stores a target as the version after `current`, unless another
+// change got in first, and records it in the target_versions history.
+// New targets, without a current version, are upserted.
+//
+// Targets saved before versioning have version 0. Their definition is recorded
+// as version 0 before it's first replaced, so that change can be rolled back too.
+func (db *Database) saveTarget(target data.Target, current *data.Target, author, comment string) (data.Target, error) {
+	previous := 0
+	if current != nil {
+		previous = current.Version
+	}
+
+	target.Version = previous + 1
+
+	filter := bson.M{"target": target.Target}
+	if previous > 0 {
+		filter["version"] = previous
+	} else {
+		// Matches a missing or null version as well
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	}
+
+	if current != nil && previous == 0 {
+		err := db.saveTargetVersion(*current, "", "before versioning")
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return target, err
+		}
+	}
+
+	upsert := current == nil
+
+	res, err := db.Collection("targets").ReplaceOne(context.TODO(), filter, target, options.Replace().SetUpsert(upsert))
+	if mongo.IsDuplicateKeyError(err) || (err == nil && !upsert && res.MatchedCount == 0) {
+		return target, fmt.Errorf("%s: %w", target.Target, ErrTargetChanged)
+	}
+
+	if err != nil {
+		return target, err
+	}
+
+	return target, db.saveTargetVersion(target, author, comment)
+}
+
+// saveTargetVersion records a version of a target in the history.
+func (db *Database) saveTargetVersion(target data.Target, author, comment string) error {
+	_, err := db.Collection("target_versions").InsertOne(context.TODO(), data.TargetVersion{
+		ID:         fmt.Sprintf("%s@%d", target.Target, target.Version),
+		Target:     target.Target,
+		Version:    target.Version,
+		Author:     author,
+		Comment:    comment,
+		SavedAt:    time.Now(),
+		Definition: target,
+	})
+
+	return err
+}
+
+// EnsureTargetIndexes makes target names unique, so concurrent creates of the
+// same target can't both succeed.
+func (db *Database) EnsureTargetIndexes() error {
+	_, err := db.Collection("targets").Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "target", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	return err
+}
+
+// GetTargetHistory lists the saved versions of a target, latest first.
+func (db *Database) GetTargetHistory(name string) ([]data.TargetVersion, error) {
+	versions := []data.TargetVersion{}
+
+	res, err := db.Collection("target_versions").Find(context.TODO(), bson.M{"target": name}, options.Find().SetSort(bson.D{{Key: "version", Value: -1}}))
+	if err != nil {
+		return versions, err
+	}
+
+	err = res.All(context.TODO(), &versions)
+
+	return versions, err
+}
+
+// GetTargetVersion fetches a saved version of a target.
+func (db *Database) GetTargetVersion(name string, version int) (data.TargetVersion, error) {
+	saved := data.TargetVersion{}
+
+	err := db.Collection("target_versions").FindOne(context.TODO(), bson.M{"target": name, "version": version}).Decode(&saved)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return saved, ErrTargetNotFound
+	}
+
+	return saved, err
+}
@@ -1,10 +1,12 @@
 package deus
 
 import (
-	"strconv"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/Cedi-Search/Cedi-Search-Engine/currency"
 	"github.com/Cedi-Search/Cedi-Search-Engine/data"
 	"github.com/Cedi-Search/Cedi-Search-Engine/database"
 	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
@@ -30,7 +32,7 @@ func queueProducts(db *database.Database, products []soup.Root) {
 		productLink := link.Attrs()["href"]
 
 		canQueue, err := db.CanQueueUrl(productLink)
-		if utils.HandleErr(err, "Failed to check Deus product queue") {
+		if utils.HandleErr(err, utils.Sniffer, "Failed to check Deus product queue") {
 			continue
 		}
 
@@ -40,7 +42,7 @@ func queueProducts(db *database.Database, products []soup.Root) {
 				Source: "Deus",
 			})
 
-			utils.HandleErr(err, "Failed to queue Deus product")
+			utils.HandleErr(err, utils.Sniffer, "Failed to queue Deus product")
 		} else {
 			utils.Logger(utils.Sniffer, source, "Skipping", productLink)
 		}
@@ -59,7 +61,10 @@ func queueProducts(db *database.Database, products []soup.Root) {
 func extractProducts(href string) []soup.Root {
 	utils.Logger(utils.Sniffer, "Extracting products from ", href)
 
-	resp := utils.FetchPage(href, "rod")
+	resp, err := utils.FetchPage(href, "rod")
+	if utils.HandleErr(err, utils.Sniffer, "Failed to fetch Deus listing") {
+		return []soup.Root{}
+	}
 
 	doc := soup.HTMLParse(resp)
 
@@ -75,21 +80,58 @@ func NewDeus(db *database.Database) *Deus {
 func (deus *Deus) Index(page data.CrawledPage) {
 	utils.Logger(utils.Indexer, source, "Indexing Deus...")
 
+	productData, err := deus.Extract(page)
+	if utils.HandleErr(err, utils.Indexer, "Failed to extract Deus product") {
+		return
+	}
+
+	err = deus.db.IndexProduct(productData.Fields())
+	if utils.HandleErr(err, utils.Indexer, "Couldn't index Deus product") {
+		return
+	}
+}
+
+// Extract reads a product off a Deus product page without saving it.
+func (deus *Deus) Extract(page data.CrawledPage) (data.Product, error) {
 	parsedPage := soup.HTMLParse(page.HTML)
 
 	productNameEl := parsedPage.Find("span", "itemprop", "name")
 
 	if productNameEl.Error != nil {
-		return
+		return data.Product{}, productNameEl.Error
 	}
 
 	productName := productNameEl.Text()
 
 	productPriceStirng := parsedPage.Find("span", "data-price-type", "finalPrice").Attrs()["data-price-amount"]
 
-	price, err := strconv.ParseFloat(productPriceStirng, 64)
-	if utils.HandleErr(err, "Failed to converted Deus product price") {
-		return
+	priceInfo, err := currency.ParseNormalized(productPriceStirng)
+	if err != nil {
+		return data.Product{}, fmt.Errorf("failed to converted Deus product price: %w", err)
+	}
+
+	price := currency.ToMajor(priceInfo.AmountGHS)
+
+	oldPrice := 0.0
+
+	oldPriceEl := parsedPage.Find("span", "data-price-type", "oldPrice")
+
+	if oldPriceEl.Error == nil {
+		oldPriceInfo, err := currency.ParseNormalized(oldPriceEl.Attrs()["data-price-amount"])
+		if err == nil {
+			priceInfo.OldAmount = oldPriceInfo.Amount
+			priceInfo.OldAmountGHS = oldPriceInfo.AmountGHS
+			oldPrice = currency.ToMajor(oldPriceInfo.AmountGHS)
+		}
+	}
+
+	promotions := []string{}
+
+	// Magento's "Sale" / "New" product labels
+	for _, el := range parsedPage.FindAll("span", "class", "product-label") {
+		if label := strings.TrimSpace(el.FullText()); el.Error == nil && label != "" {
+			promotions = append(promotions, label)
+		}
 	}
 
 	productDescription := ""
@@ -103,19 +145,20 @@ func (deus *Deus) Index(page data.CrawledPage) {
 	productImage := parsedPage.Find("img", "class", "no-sirv-lazy-load").Attrs()["src"]
 
 	productData := data.Product{
-		Name:        productName,
-		Price:       price,
-		Rating:      0,
-		Description: productDescription,
-		URL:         page.URL,
-		Source:      page.Source,
-		Images:      []string{productImage},
+		Name:            productName,
+		Price:           price,
+		PriceInfo:       priceInfo,
+		OldPrice:        oldPrice,
+		DiscountPercent: currency.DiscountPercent(price, oldPrice),
+		Promotions:      promotions,
+		Rating:          0,
+		Description:     productDescription,
+		URL:             page.URL,
+		Source:          page.Source,
+		Images:          []string{productImage},
 	}
 
-	err = deus.db.IndexProduct(productData)
-	if utils.HandleErr(err, "Couldn't index Deus product") {
-		return
-	}
+	return productData, nil
 }
 
 func (deus *Deus) Sniff(wg *sync.WaitGroup) {
@@ -123,7 +166,10 @@ func (deus *Deus) Sniff(wg *sync.WaitGroup) {
 
 	defer wg.Done()
 
-	resp := utils.FetchPage("https://deus.com.gh/", "rod")
+	resp, err := utils.FetchPage("https://deus.com.gh/", "rod")
+	if utils.HandleErr(err, utils.Sniffer, "Failed to fetch Deus home page") {
+		return
+	}
 
 	doc := soup.HTMLParse(resp)
 
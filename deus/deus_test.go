@@ -0,0 +1,11 @@
+package deus
+
+import (
+	"testing"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/golden"
+)
+
+func TestExtractGolden(t *testing.T) {
+	golden.Run(t, golden.Dir(source), source, "site", NewDeus(nil).Extract)
+}
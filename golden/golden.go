@@ -0,0 +1,184 @@
+// Package golden checks extractors against saved product pages.
+//
+// The pages of a source live once in testdata/golden/<source> at the root of
+// the repository, as <name>.html, and are read by every extractor of the source.
+// Each extractor keeps the products it's expected to extract next to them, as
+// <name>.<extractor>.json, e.g. <name>.site.json for the site package and
+// <name>.indexer.json for the generic indexer.
+//
+// The pages there now are hand-written after each shop's product page markup,
+// so they catch extraction changes but not the shops changing their markup.
+// Pages captured with "extract --save" and trimmed to the product's part of
+// the page can replace them, or be added next to them. The first line of each
+// page is an HTML comment with the URL it was saved from, which is what
+// "extract --save" writes:
+//
+//	<!-- https://www.jumia.com.gh/tecno-spark-10-pro-128gb-48823011.html -->
+//
+// Run the tests with UPDATE_GOLDEN=1 to write the extracted products to the
+// JSON files instead of comparing them, then review the diff before committing.
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+)
+
+// Extractor reads a product off a page, like the Extract methods of the site packages.
+type Extractor func(page data.CrawledPage) (data.Product, error)
+
+// T is the part of *testing.T the harness uses, so this package doesn't
+// import testing. Run is for subtests, e.g. *testing.T is a T[*testing.T].
+type T[Sub any] interface {
+	Helper()
+	Fatalf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Run(name string, f func(Sub)) bool
+}
+
+// Update reports whether golden files are to be rewritten, as asked with UPDATE_GOLDEN=1.
+func Update() bool {
+	return os.Getenv("UPDATE_GOLDEN") == "1"
+}
+
+var urlComment = regexp.MustCompile(`^\s*<!--\s*(\S+)\s*-->`)
+
+// Dir is the fixture directory of a source, relative to a package directory.
+func Dir(source string) string {
+	return filepath.Join("..", "testdata", "golden", strings.ToLower(source))
+}
+
+// Run extracts a product from every page in dir as a subtest and compares it
+// with the page's golden file for the extractor, e.g. "site", or rewrites the
+// golden file in update mode.
+func Run[Sub T[Sub]](t Sub, dir, source, extractor string, extract Extractor) {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if len(files) == 0 {
+		t.Fatalf("no pages in %s", dir)
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".html")
+
+		t.Run(name, func(t Sub) {
+			page, err := Page(file, source)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+
+			product, err := extract(page)
+			if err != nil {
+				t.Fatalf("extracting %s: %v", file, err)
+			}
+
+			err = Compare(strings.TrimSuffix(file, ".html")+"."+extractor+".json", product)
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+		})
+	}
+}
+
+// Page reads a saved product page as if it had just been crawled.
+func Page(file, source string) (data.CrawledPage, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return data.CrawledPage{}, err
+	}
+
+	match := urlComment.FindSubmatch(content)
+	if match == nil {
+		return data.CrawledPage{}, errors.New(file + " doesn't start with a <!-- url --> comment")
+	}
+
+	return data.CrawledPage{
+		URL:    string(match[1]),
+		HTML:   string(content),
+		Source: source,
+		Status: 200,
+	}, nil
+}
+
+// Compare checks a product against a golden file, or rewrites the file in update mode.
+// The error of a product that differs lists the lines that do.
+func Compare(file string, product data.Product) error {
+	got, err := json.MarshalIndent(product, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	got = append(got, '\n')
+
+	if Update() {
+		return os.WriteFile(file, got, 0o644)
+	}
+
+	want, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s doesn't exist, run the tests with UPDATE_GOLDEN=1 to create it", file)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("product differs from %s (run with UPDATE_GOLDEN=1 to accept it)\n%s", file, diff(string(want), string(got)))
+	}
+
+	return nil
+}
+
+// FromFields converts the field map the generic indexer extracts into a product.
+func FromFields(fields map[string]interface{}) (data.Product, error) {
+	product := data.Product{}
+
+	content, err := json.Marshal(fields)
+	if err != nil {
+		return product, err
+	}
+
+	err = json.Unmarshal(content, &product)
+
+	return product, err
+}
+
+// diff lists the lines of two JSON documents that differ, as "-want" and "+got".
+func diff(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+
+	lines := []string{}
+
+	for i := 0; i < max(len(wantLines), len(gotLines)); i++ {
+		wantLine, gotLine := "", ""
+
+		if i < len(wantLines) {
+			wantLine = wantLines[i]
+		}
+
+		if i < len(gotLines) {
+			gotLine = gotLines[i]
+		}
+
+		if wantLine != gotLine {
+			lines = append(lines, "-"+wantLine, "+"+gotLine)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
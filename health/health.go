@@ -0,0 +1,279 @@
+package health
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/config"
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+	"github.com/Cedi-Search/Cedi-Search-Engine/indexer"
+	"github.com/Cedi-Search/Cedi-Search-Engine/metrics"
+	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
+)
+
+// Alert is what gets posted to HEALTH_WEBHOOK_URL when a field breaks or recovers.
+type Alert struct {
+	Status string `json:"status"`
+	data.FieldHealth
+}
+
+// Alert statuses.
+const (
+	Broken    = "broken"
+	Recovered = "recovered"
+)
+
+// outcome is whether a field was extracted from a page.
+type outcome struct {
+	url    string
+	filled bool
+}
+
+// window holds the outcomes of a field over a source's latest pages.
+type window struct {
+	source   string
+	field    string
+	required bool
+	outcomes []outcome
+	next     int
+	// added counts the outcomes since the last check.
+	added int
+}
+
+// add records an outcome, pushing out the oldest once the window is full.
+func (w *window) add(o outcome) {
+	w.added++
+
+	if len(w.outcomes) < config.HEALTH_WINDOW {
+		w.outcomes = append(w.outcomes, o)
+		return
+	}
+
+	w.outcomes[w.next] = o
+	w.next = (w.next + 1) % config.HEALTH_WINDOW
+}
+
+// fillRate returns the share of pages the field was extracted from,
+// and the most recent pages it wasn't.
+func (w *window) fillRate() (float64, []string) {
+	filled := 0
+	failing := []string{}
+
+	for i := range w.outcomes {
+		// Newest first
+		o := w.outcomes[(w.next-1-i+2*len(w.outcomes))%len(w.outcomes)]
+
+		if o.filled {
+			filled++
+		} else if len(failing) < config.HEALTH_SAMPLE_URLS {
+			failing = append(failing, o.url)
+		}
+	}
+
+	return float64(filled) / float64(len(w.outcomes)), failing
+}
+
+// Monitor tracks how often each field of each source is extracted and alerts
+// when a field's fill rate drops well below its baseline, which usually means
+// the shop changed its markup and the target's selector no longer matches.
+//
+// Baselines are kept in the database so they survive restarts. They follow the
+// fill rate while a field is healthy and new pages come in, and are frozen
+// while it's alerting.
+type Monitor struct {
+	db      *database.Database
+	webhook string
+	client  *http.Client
+
+	mu      sync.Mutex
+	windows map[string]*window
+	health  map[string]data.FieldHealth
+}
+
+// NewMonitor creates a Monitor that posts alerts to HEALTH_WEBHOOK_URL, if set.
+// Add it as an extraction observer of the crawler and run it with Run.
+func NewMonitor(db *database.Database) *Monitor {
+	return &Monitor{
+		db:      db,
+		webhook: os.Getenv("HEALTH_WEBHOOK_URL"),
+		client:  &http.Client{Timeout: 15 * time.Second},
+		windows: map[string]*window{},
+		health:  map[string]data.FieldHealth{},
+	}
+}
+
+// ObserveExtraction implements indexer.ExtractionObserver.
+func (monitor *Monitor) ObserveExtraction(page data.CrawledPage, fields []indexer.Field) {
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+
+	for _, field := range fields {
+		key := page.Source + "/" + field.Label
+
+		w, found := monitor.windows[key]
+		if !found {
+			w = &window{source: page.Source, field: field.Label}
+			monitor.windows[key] = w
+		}
+
+		w.required = field.Required
+		w.add(outcome{url: page.URL, filled: field.Filled()})
+	}
+}
+
+// Run checks the fill rates every config.HEALTH_CHECK_INTERVAL until the process is stopped.
+func (monitor *Monitor) Run() {
+	previous, err := monitor.db.GetFieldHealth("")
+	utils.HandleErr(err, utils.Health, "Failed to get extraction health")
+
+	monitor.mu.Lock()
+	for _, health := range previous {
+		monitor.health[health.Source+"/"+health.Field] = health
+	}
+	monitor.mu.Unlock()
+
+	for {
+		time.Sleep(config.HEALTH_CHECK_INTERVAL)
+
+		monitor.Check()
+	}
+}
+
+// Check compares every field's fill rate with its baseline, alerting about
+// fields that broke or recovered since the last check, and saves the results.
+// Fields without new pages since the last check are left as they are.
+func (monitor *Monitor) Check() {
+	monitor.mu.Lock()
+
+	checked := []data.FieldHealth{}
+	alerts := []Alert{}
+
+	for key, w := range monitor.windows {
+		if len(w.outcomes) < config.HEALTH_MIN_PAGES || w.added == 0 {
+			continue
+		}
+
+		health, found := monitor.health[key]
+		if !found {
+			health = data.FieldHealth{Source: w.source, Field: w.field}
+		}
+
+		health, alert := evaluate(health, w)
+		if alert != nil {
+			alerts = append(alerts, *alert)
+		}
+
+		w.added = 0
+
+		monitor.health[key] = health
+		checked = append(checked, health)
+
+		metrics.FillRate.WithLabelValues(health.Source, health.Field).Set(health.FillRate)
+	}
+
+	monitor.mu.Unlock()
+
+	for _, health := range checked {
+		err := monitor.db.SaveFieldHealth(health)
+		utils.HandleErr(err, utils.Health, "Failed to save extraction health", "source", health.Source, "field", health.Field)
+	}
+
+	for _, alert := range alerts {
+		monitor.alert(alert)
+	}
+}
+
+// evaluate updates a field's health with the fill rate of its window and
+// returns an alert if the field broke or recovered.
+//
+// A field is broken when its fill rate is below config.HEALTH_ALERT_RATIO of its
+// baseline, or, for required fields, below config.HEALTH_MIN_FILL_RATE, which
+// also catches fields that were already broken when they were first seen.
+func evaluate(health data.FieldHealth, w *window) (data.FieldHealth, *Alert) {
+	health.FillRate, health.FailingURLs = w.fillRate()
+	health.Pages = len(w.outcomes)
+	health.CheckedAt = time.Now()
+
+	broken := health.FillRate < health.Baseline*config.HEALTH_ALERT_RATIO ||
+		(w.required && health.FillRate < config.HEALTH_MIN_FILL_RATE)
+
+	var alert *Alert
+
+	switch {
+	case broken && !health.Alerting:
+		health.Alerting = true
+		alert = &Alert{Status: Broken, FieldHealth: health}
+	case !broken && health.Alerting:
+		health.Alerting = false
+		alert = &Alert{Status: Recovered, FieldHealth: health}
+	}
+
+	if !health.Alerting {
+		health.Baseline = baseline(health)
+	}
+
+	return health, alert
+}
+
+// baseline moves a healthy field's baseline towards its current fill rate.
+// A field seen for the first time starts at its current fill rate.
+func baseline(health data.FieldHealth) float64 {
+	if health.Baseline == 0 {
+		return health.FillRate
+	}
+
+	return 0.9*health.Baseline + 0.1*health.FillRate
+}
+
+// alert logs a field that broke or recovered and posts it to the webhook.
+func (monitor *Monitor) alert(alert Alert) {
+	log := utils.Log(utils.Health).With("source", alert.Source, "field", alert.Field, "fill_rate", alert.FillRate, "baseline", alert.Baseline, "pages", alert.Pages)
+
+	if alert.Status == Broken {
+		log.Error("Field extraction broke", "failing_urls", alert.FailingURLs)
+	} else {
+		log.Info("Field extraction recovered")
+	}
+
+	if monitor.webhook == "" {
+		return
+	}
+
+	err := monitor.post(alert)
+	utils.HandleErr(err, utils.Health, "Failed to post extraction health alert", "source", alert.Source, "field", alert.Field)
+}
+
+// post sends an alert to the webhook as JSON.
+func (monitor *Monitor) post(alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, monitor.webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", config.USER_AGENT)
+
+	res, err := monitor.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s responded with %s", monitor.webhook, res.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package health
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/config"
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+)
+
+// newWindow fills a window with outcomes for pages 0 to len(filled)-1.
+func newWindow(required bool, filled ...bool) *window {
+	w := &window{source: "Jumia", field: "price", required: required}
+
+	for i, f := range filled {
+		w.add(outcome{url: fmt.Sprint(i), filled: f})
+	}
+
+	return w
+}
+
+// outcomes repeats an outcome n times.
+func outcomes(n int, filled bool) []bool {
+	all := make([]bool, n)
+	for i := range all {
+		all[i] = filled
+	}
+
+	return all
+}
+
+func TestWindowFillRate(t *testing.T) {
+	w := newWindow(false, true, false, true, false)
+
+	rate, failing := w.fillRate()
+	if rate != 0.5 {
+		t.Errorf("fill rate = %v, want 0.5", rate)
+	}
+
+	if want := []string{"3", "1"}; !reflect.DeepEqual(failing, want) {
+		t.Errorf("failing = %v, want newest first %v", failing, want)
+	}
+}
+
+func TestWindowWrapsAround(t *testing.T) {
+	// The oldest pages are filled, the ones that push them out are not.
+	w := newWindow(false, append(outcomes(config.HEALTH_WINDOW, true), outcomes(config.HEALTH_WINDOW/4, false)...)...)
+
+	if len(w.outcomes) != config.HEALTH_WINDOW {
+		t.Fatalf("window holds %d outcomes, want %d", len(w.outcomes), config.HEALTH_WINDOW)
+	}
+
+	rate, failing := w.fillRate()
+	if rate != 0.75 {
+		t.Errorf("fill rate = %v, want 0.75", rate)
+	}
+
+	last := config.HEALTH_WINDOW + config.HEALTH_WINDOW/4 - 1
+	if len(failing) != config.HEALTH_SAMPLE_URLS || failing[0] != fmt.Sprint(last) {
+		t.Errorf("failing = %v, want %d URLs starting with %d", failing, config.HEALTH_SAMPLE_URLS, last)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name         string
+		health       data.FieldHealth
+		window       *window
+		wantAlert    string
+		wantAlerting bool
+		wantBaseline float64
+	}{
+		{
+			name:         "healthy",
+			health:       data.FieldHealth{Baseline: 1},
+			window:       newWindow(true, outcomes(40, true)...),
+			wantBaseline: 1,
+		},
+		{
+			name:         "drops below baseline",
+			health:       data.FieldHealth{Baseline: 0.5},
+			window:       newWindow(false, append(outcomes(10, true), outcomes(30, false)...)...),
+			wantAlert:    Broken,
+			wantAlerting: true,
+			wantBaseline: 0.5,
+		},
+		{
+			name:         "stays broken",
+			health:       data.FieldHealth{Baseline: 1, Alerting: true},
+			window:       newWindow(true, outcomes(40, false)...),
+			wantAlerting: true,
+			wantBaseline: 1,
+		},
+		{
+			name:         "recovers",
+			health:       data.FieldHealth{Baseline: 1, Alerting: true},
+			window:       newWindow(true, outcomes(40, true)...),
+			wantAlert:    Recovered,
+			wantBaseline: 1,
+		},
+		{
+			name:         "required field broken from the start",
+			window:       newWindow(true, append(outcomes(10, true), outcomes(30, false)...)...),
+			wantAlert:    Broken,
+			wantAlerting: true,
+		},
+		{
+			name:         "optional field sparse from the start",
+			window:       newWindow(false, append(outcomes(10, true), outcomes(30, false)...)...),
+			wantBaseline: 0.25,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			health, alert := evaluate(test.health, test.window)
+
+			status := ""
+			if alert != nil {
+				status = alert.Status
+			}
+
+			if status != test.wantAlert {
+				t.Errorf("alert = %q, want %q", status, test.wantAlert)
+			}
+
+			if health.Alerting != test.wantAlerting {
+				t.Errorf("alerting = %v, want %v", health.Alerting, test.wantAlerting)
+			}
+
+			if health.Baseline != test.wantBaseline {
+				t.Errorf("baseline = %v, want %v", health.Baseline, test.wantBaseline)
+			}
+		})
+	}
+}
+
+func TestCheckSkipsWindowsWithoutNewPages(t *testing.T) {
+	monitor := &Monitor{windows: map[string]*window{}, health: map[string]data.FieldHealth{}}
+
+	w := newWindow(false, outcomes(config.HEALTH_MIN_PAGES, true)...)
+	w.added = 0
+
+	monitor.windows["Jumia/price"] = w
+	monitor.health["Jumia/price"] = data.FieldHealth{Source: "Jumia", Field: "price", Baseline: 0.5}
+
+	monitor.Check()
+
+	if baseline := monitor.health["Jumia/price"].Baseline; baseline != 0.5 {
+		t.Errorf("baseline moved to %v without new pages", baseline)
+	}
+}
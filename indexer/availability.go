@@ -0,0 +1,73 @@
+package indexer
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/anaskhan96/soup"
+)
+
+// jsonLDAvailabilityRe finds the availability of an offer in JSON-LD, e.g.
+// "availability": "https://schema.org/InStock"
+var jsonLDAvailabilityRe = regexp.MustCompile(`"availability"\s*:\s*"([^"]+)"`)
+
+// NormalizeAvailability maps a schema.org availability value, or the text
+// shops put on their pages, to one of data.InStock, data.OutOfStock,
+// data.PreOrder or data.Removed. It returns "" when the text is not recognised.
+func NormalizeAvailability(text string) string {
+	text = strings.ToLower(text)
+	text = strings.NewReplacer(" ", "", "-", "", "_", "").Replace(text)
+
+	switch {
+	case strings.Contains(text, "outofstock"), strings.Contains(text, "soldout"), strings.Contains(text, "unavailable"):
+		return data.OutOfStock
+	case strings.Contains(text, "discontinued"), strings.Contains(text, "closed"), strings.Contains(text, "expired"):
+		return data.Removed
+	case strings.Contains(text, "preorder"), strings.Contains(text, "backorder"):
+		return data.PreOrder
+	case strings.Contains(text, "instock"), strings.Contains(text, "limitedavailability"), strings.Contains(text, "onlineonly"), strings.Contains(text, "instoreonly"), strings.Contains(text, "addtocart"), strings.Contains(text, "buynow"):
+		return data.InStock
+	}
+
+	return ""
+}
+
+// extractAvailability finds a product's availability in schema.org microdata
+// or JSON-LD. It returns "" when the page doesn't say.
+func extractAvailability(parsedPage soup.Root, html string) string {
+	for _, el := range parsedPage.FindAll("link", "itemprop", "availability") {
+		if availability := NormalizeAvailability(el.Attrs()["href"]); availability != "" {
+			return availability
+		}
+	}
+
+	for _, el := range parsedPage.FindAll("meta", "itemprop", "availability") {
+		if availability := NormalizeAvailability(el.Attrs()["content"]); availability != "" {
+			return availability
+		}
+	}
+
+	for _, match := range jsonLDAvailabilityRe.FindAllStringSubmatch(html, -1) {
+		if availability := NormalizeAvailability(match[1]); availability != "" {
+			return availability
+		}
+	}
+
+	return ""
+}
+
+// AvailabilityRank orders availabilities for search ranking, so
+// unavailable products are demoted below those that can be bought.
+func AvailabilityRank(availability string) int {
+	switch availability {
+	case data.InStock, "":
+		return 3
+	case data.PreOrder:
+		return 2
+	case data.OutOfStock:
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,35 @@
+package indexer_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/golden"
+	"github.com/Cedi-Search/Cedi-Search-Engine/indexer"
+	"github.com/Cedi-Search/Cedi-Search-Engine/targets"
+)
+
+// TestExtractGolden runs every source with a target.yaml among the golden
+// fixtures against the saved pages next to it.
+func TestExtractGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "testdata", "golden", "*", "target.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		target, errs := targets.LoadFile(file)
+		for _, err := range errs {
+			t.Fatal(err)
+		}
+
+		t.Run(target.Target, func(t *testing.T) {
+			golden.Run(t, filepath.Dir(file), target.Target, "indexer", func(page data.CrawledPage) (data.Product, error) {
+				page.Attribs = target.Data
+
+				return golden.FromFields(indexer.NewIndexer(nil).Extract(page))
+			})
+		})
+	}
+}
@@ -1,16 +1,36 @@
 package indexer
 
 import (
+	"context"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 
+	"github.com/Cedi-Search/Cedi-Search-Engine/currency"
 	"github.com/Cedi-Search/Cedi-Search-Engine/data"
 	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+	"github.com/Cedi-Search/Cedi-Search-Engine/metrics"
+	"github.com/Cedi-Search/Cedi-Search-Engine/tracing"
 	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
 	"github.com/anaskhan96/soup"
 )
 
 type Indexer struct {
-	db *database.Database
+	db        *database.Database
+	observers []ExtractionObserver
+}
+
+// ExtractionObserver is told how every page Index sees was extracted,
+// including pages that aren't saved for missing required fields.
+type ExtractionObserver interface {
+	ObserveExtraction(page data.CrawledPage, fields []Field)
+}
+
+// AddExtractionObserver registers an observer to be called, in the
+// indexing goroutine, for every page Index extracts.
+func (indexer *Indexer) AddExtractionObserver(observer ExtractionObserver) {
+	indexer.observers = append(indexer.observers, observer)
 }
 
 func NewIndexer(database *database.Database) *Indexer {
@@ -19,12 +39,69 @@ func NewIndexer(database *database.Database) *Indexer {
 	}
 }
 
-func (indexer *Indexer) Index(page data.CrawledPage) error {
-	utils.Logger(utils.Indexer, page.Source, fmt.Sprintf("Indexing %v...", page.Source))
+// Index extracts a product from a crawled page and saves it, tracing
+// both steps as children of the span in ctx.
+//
+// Pages missing a required field aren't saved; a *MissingFieldsError is returned instead.
+func (indexer *Indexer) Index(ctx context.Context, page data.CrawledPage) error {
+	utils.Log(utils.Indexer).Debug("Indexing", "source", page.Source, "url", page.URL)
+
+	_, span := tracing.Start(ctx, "extract")
+
+	productData, fields := indexer.ExtractFields(page)
+
+	for _, observer := range indexer.observers {
+		observer.ObserveExtraction(page, fields)
+	}
+
+	if missing := missingFields(page.Attribs, productData); len(missing) > 0 {
+		err := &MissingFieldsError{Fields: missing}
+		tracing.End(span, err)
+		return err
+	}
+
+	span.End()
+
+	_, span = tracing.Start(ctx, "store")
+
+	err := indexer.db.IndexProduct(productData)
+
+	tracing.End(span, err)
+
+	return err
+}
+
+// Field is what extracting one attribute of a page went through: the elements
+// its selector matched, their raw text, the value it was turned into and
+// what went wrong, if anything.
+type Field struct {
+	Label    string
+	Required bool
+	Elements []string
+	Raw      []string
+	Value    interface{}
+	Error    string
+}
+
+// Filled reports whether the field got a value.
+func (field Field) Filled() bool {
+	return field.Error == "" && !isEmpty(field.Value)
+}
+
+// Extract runs the page's attribute selectors against its HTML
+// and returns the resulting product data without saving it.
+func (indexer *Indexer) Extract(page data.CrawledPage) map[string]interface{} {
+	productData, _ := indexer.ExtractFields(page)
 
+	return productData
+}
+
+// ExtractFields is Extract, also reporting how each of the page's attributes was extracted.
+func (indexer *Indexer) ExtractFields(page data.CrawledPage) (map[string]interface{}, []Field) {
 	parsedPage := soup.HTMLParse(page.HTML)
 
 	productData := make(map[string]interface{})
+	fields := []Field{}
 
 	productData["url"] = page.URL
 	productData["source"] = page.Source
@@ -38,18 +115,20 @@ func (indexer *Indexer) Index(page data.CrawledPage) error {
 			} else {
 				productData[attrib.Label] = ""
 			}
-		} else if attrib.DataType == "number" {
+		} else if attrib.DataType == "number" || attrib.DataType == "price" {
 			if attrib.IsArray {
 				productData[attrib.Label] = []float64{}
 			} else {
-				productData[attrib.Label] = 0
+				productData[attrib.Label] = 0.0
 			}
 		}
 
-		if attrib.Selector.Attribute != "" && attrib.Selector.Attribute != "" {
+		if attrib.Selector.Attribute != "" && attrib.Selector.Value != "" {
 			args = append(args, attrib.Selector.Attribute, attrib.Selector.Value)
 		}
 
+		field := Field{Label: attrib.Label}
+
 		if attrib.IsArray {
 			els := parsedPage.FindAll(args...)
 
@@ -71,15 +150,31 @@ func (indexer *Indexer) Index(page data.CrawledPage) error {
 
 				elItems = append(elItems, item)
 
+				field.Elements = append(field.Elements, openingTag(el))
 			}
 
-			productData[attrib.Label] = elItems
+			field.Raw = elItems
+
+			if len(elItems) == 0 {
+				extractionFailed(page, attrib, "not_found")
+				field.Error = "no element matches the selector"
+			}
+
+			if attrib.DataType == "number" || attrib.DataType == "price" {
+				productData[attrib.Label] = parseNumbers(elItems)
+			} else {
+				productData[attrib.Label] = elItems
+			}
 
 		} else {
 
 			el := parsedPage.Find(args...)
 
 			if el.Error != nil {
+				extractionFailed(page, attrib, "not_found")
+				field.Error = "no element matches the selector"
+				field.Value = productData[attrib.Label]
+				fields = append(fields, field)
 				continue
 			}
 
@@ -87,20 +182,168 @@ func (indexer *Indexer) Index(page data.CrawledPage) error {
 
 			if attrib.ChildAttrib != "" {
 				item = el.Attrs()[attrib.ChildAttrib]
+			} else {
+				item = el.FullText()
 			}
 
-			item = el.FullText()
+			field.Elements = []string{openingTag(el)}
+			field.Raw = []string{item}
+
+			if isPrice(attrib) {
+				price, err := currency.Parse(item)
+				if err != nil {
+					extractionFailed(page, attrib, "parse")
+					field.Error = err.Error()
+				} else {
+					productData[attrib.Label+"_info"] = price
 
-			productData[attrib.Label] = item
+					err = currency.Normalize(&price)
+					if utils.HandleErr(err, utils.Indexer, "Failed to convert price", "field", attrib.Label, "url", page.URL, "currency", currency.Base) {
+						extractionFailed(page, attrib, "convert")
+						field.Error = err.Error()
+					} else {
+						productData[attrib.Label+"_info"] = price
+						productData[attrib.Label] = currency.ToMajor(price.AmountGHS)
+					}
+				}
+			} else if attrib.DataType == "number" {
+				number, err := parseNumber(item)
+				if err != nil {
+					extractionFailed(page, attrib, "parse")
+					field.Error = err.Error()
+				} else {
+					productData[attrib.Label] = number
+				}
+			} else {
+				productData[attrib.Label] = item
+			}
 
 		}
 
+		field.Value = productData[attrib.Label]
+		fields = append(fields, field)
 	}
 
-	err := indexer.db.IndexProduct(productData)
-	if err != nil {
-		return err
+	applyDiscount(productData)
+
+	// A target's own "availability" selector wins over schema.org markup.
+	availability := ""
+
+	if text, ok := productData["availability"].(string); ok {
+		availability = NormalizeAvailability(text)
+	}
+
+	if availability == "" {
+		availability = extractAvailability(parsedPage, page.HTML)
+	}
+
+	productData["availability"] = availability
+	productData["availability_rank"] = AvailabilityRank(availability)
+
+	for _, label := range requiredLabels(page.Attribs) {
+		for i := range fields {
+			if fields[i].Label == label {
+				fields[i].Required = true
+			}
+		}
+	}
+
+	for _, label := range missingFields(page.Attribs, productData) {
+		for i := range fields {
+			if fields[i].Label == label && fields[i].Error == "" {
+				fields[i].Error = "required but empty"
+			}
+		}
+	}
+
+	return productData, fields
+}
+
+// openingTag renders an element's opening tag, e.g. <span class="-b -prc">,
+// which is usually enough to tell which element a selector matched.
+func openingTag(el soup.Root) string {
+	tag := "<" + el.NodeValue
+
+	for _, attr := range el.Pointer.Attr {
+		tag += fmt.Sprintf(" %s=%q", attr.Key, attr.Val)
+	}
+
+	return tag + ">"
+}
+
+// extractionFailed counts an attribute the page didn't yield a value for.
+func extractionFailed(page data.CrawledPage, attrib data.Data, reason string) {
+	metrics.ExtractionFailures.WithLabelValues(page.Source, attrib.Label, reason).Inc()
+}
+
+// applyDiscount fills in the discount fields of a product from its
+// "old_price" and "promotions" attributes, when the target extracts them.
+func applyDiscount(productData map[string]interface{}) {
+	price, _ := productData["price"].(float64)
+	oldPrice, _ := productData["old_price"].(float64)
+
+	productData["old_price"] = oldPrice
+	productData["discount_percent"] = currency.DiscountPercent(price, oldPrice)
+
+	if priceInfo, ok := productData["price_info"].(data.Price); ok {
+		if oldPriceInfo, ok := productData["old_price_info"].(data.Price); ok && oldPriceInfo.Currency == priceInfo.Currency {
+			priceInfo.OldAmount = oldPriceInfo.Amount
+			priceInfo.OldAmountGHS = oldPriceInfo.AmountGHS
+			productData["price_info"] = priceInfo
+		}
+	}
+
+	delete(productData, "old_price_info")
+
+	promotions := []string{}
+
+	switch labels := productData["promotions"].(type) {
+	case []string:
+		for _, label := range labels {
+			if label = strings.Join(strings.Fields(label), " "); label != "" {
+				promotions = append(promotions, label)
+			}
+		}
+	case string:
+		if label := strings.Join(strings.Fields(labels), " "); label != "" {
+			promotions = append(promotions, label)
+		}
+	}
+
+	productData["promotions"] = promotions
+}
+
+// isPrice reports whether an attribute holds a price. Targets written before
+// the "price" data type existed declare their price as a "number".
+func isPrice(attrib data.Data) bool {
+	return attrib.DataType == "price" || (attrib.DataType == "number" && attrib.Label == "price")
+}
+
+var numberRe = regexp.MustCompile(`-?\d[\d,]*(\.\d+)?`)
+
+// parseNumber parses the first number in a text, ignoring currency
+// markers and thousands separators. e.g. "GH₵ 1,150.00" is 1150.
+func parseNumber(text string) (float64, error) {
+	match := numberRe.FindString(text)
+	if match == "" {
+		return 0, fmt.Errorf("no number in %q", text)
+	}
+
+	return strconv.ParseFloat(strings.ReplaceAll(match, ",", ""), 64)
+}
+
+// parseNumbers parses every text that contains a number, skipping the rest.
+func parseNumbers(texts []string) []float64 {
+	numbers := []float64{}
+
+	for _, text := range texts {
+		number, err := parseNumber(text)
+		if err != nil {
+			continue
+		}
+
+		numbers = append(numbers, number)
 	}
 
-	return nil
+	return numbers
 }
@@ -0,0 +1,72 @@
+package indexer
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+)
+
+// MissingFieldsError is returned when a page lacks fields every product page
+// of its target has. It usually means the shop served something else, such as
+// a block page, rather than that the product has no name or price.
+type MissingFieldsError struct {
+	Fields []string
+}
+
+func (err *MissingFieldsError) Error() string {
+	return "missing required fields: " + strings.Join(err.Fields, ", ")
+}
+
+// requiredLabels lists the labels of a target's required attributes.
+// Targets that mark none require their name and price, if they extract them.
+func requiredLabels(attribs []data.Data) []string {
+	labels := []string{}
+
+	for _, attrib := range attribs {
+		if attrib.Required {
+			labels = append(labels, attrib.Label)
+		}
+	}
+
+	if len(labels) > 0 {
+		return labels
+	}
+
+	for _, attrib := range attribs {
+		if attrib.Label == "name" || attrib.Label == "price" {
+			labels = append(labels, attrib.Label)
+		}
+	}
+
+	return labels
+}
+
+// missingFields lists the required labels a product has no value for.
+func missingFields(attribs []data.Data, product map[string]interface{}) []string {
+	missing := []string{}
+
+	for _, label := range requiredLabels(attribs) {
+		if isEmpty(product[label]) {
+			missing = append(missing, label)
+		}
+	}
+
+	return missing
+}
+
+// isEmpty reports whether an extracted value is the zero value or an empty list.
+func isEmpty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(value)
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String:
+		return v.Len() == 0 || (v.Kind() == reflect.String && strings.TrimSpace(v.String()) == "")
+	}
+
+	return v.IsZero()
+}
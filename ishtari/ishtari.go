@@ -3,10 +3,10 @@ package ishtari
 import (
 	"fmt"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
+	"github.com/Cedi-Search/Cedi-Search-Engine/currency"
 	"github.com/Cedi-Search/Cedi-Search-Engine/data"
 	"github.com/Cedi-Search/Cedi-Search-Engine/database"
 	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
@@ -39,7 +39,7 @@ func queueProducts(db *database.Database, products []soup.Root) {
 		productLink := fmt.Sprintf("https://ishtari.com.gh%s", link.Attrs()["href"])
 
 		canQueue, err := db.CanQueueUrl(productLink)
-		if utils.HandleErr(err, "Failed to get Ishtari queue") {
+		if utils.HandleErr(err, utils.Sniffer, "Failed to get Ishtari queue") {
 			return
 		}
 
@@ -49,7 +49,7 @@ func queueProducts(db *database.Database, products []soup.Root) {
 				Source: "Ishtari",
 			})
 
-			utils.HandleErr(err, "Failed to add Ishtari to queue")
+			utils.HandleErr(err, utils.Sniffer, "Failed to add Ishtari to queue")
 		} else {
 			utils.Logger(utils.Sniffer, source, "Skipping", productLink)
 		}
@@ -68,7 +68,10 @@ func queueProducts(db *database.Database, products []soup.Root) {
 func extractProducts(href string) ([]soup.Root, int) {
 	utils.Logger(utils.Sniffer, source, "Extracting products from ", href)
 
-	resp := utils.FetchPage(href, "rod")
+	resp, err := utils.FetchPage(href, "rod")
+	if utils.HandleErr(err, utils.Sniffer, "Failed to fetch Ishtari listing") {
+		return []soup.Root{}, 0
+	}
 
 	doc := soup.HTMLParse(resp)
 
@@ -76,13 +79,11 @@ func extractProducts(href string) ([]soup.Root, int) {
 
 	totalPages := 0
 
-	var err error
-
 	if paginationEl.Error == nil {
 		paginationChildren := paginationEl.Children()
 
 		totalPages, err = strconv.Atoi(paginationChildren[len(paginationChildren)-2].FullText())
-		if utils.HandleErr(err, "Failed to convert Ishtari product price") {
+		if utils.HandleErr(err, utils.Sniffer, "Failed to convert Ishtari product price") {
 			return []soup.Root{}, 0
 		}
 
@@ -94,23 +95,38 @@ func extractProducts(href string) ([]soup.Root, int) {
 func (ishtari *Ishtari) Index(page data.CrawledPage) {
 	utils.Logger(utils.Indexer, source, "Indexing Ishtari...")
 
+	productData, err := ishtari.Extract(page)
+	if utils.HandleErr(err, utils.Indexer, "Failed to extract Ishtari product") {
+		return
+	}
+
+	err = ishtari.db.IndexProduct(productData.Fields())
+	if utils.HandleErr(err, utils.Indexer, "Failed to index Ishtari product") {
+		return
+	}
+}
+
+// Extract reads a product off a Ishtari product page without saving it.
+func (ishtari *Ishtari) Extract(page data.CrawledPage) (data.Product, error) {
 	parsedPage := soup.HTMLParse(page.HTML)
 
 	productNameEl := parsedPage.Find("h1", "class", "text-d22")
 
 	if productNameEl.Error != nil {
-		return
+		return data.Product{}, productNameEl.Error
 	}
 
 	productName := productNameEl.Text()
 
-	productPriceStirng := strings.ReplaceAll(parsedPage.Find("span", "class", "false").Text(), " GH¢", "")
+	productPriceStirng := parsedPage.Find("span", "class", "false").Text()
 
-	price, err := strconv.ParseFloat(strings.ReplaceAll(productPriceStirng, ",", ""), 64)
-	if utils.HandleErr(err, "Failed to parse Ishtari product price") {
-		return
+	priceInfo, err := currency.ParseNormalized(productPriceStirng)
+	if err != nil {
+		return data.Product{}, fmt.Errorf("failed to parse Ishtari product price: %w", err)
 	}
 
+	price := currency.ToMajor(priceInfo.AmountGHS)
+
 	productDescription := parsedPage.Find("div", "class", "my-content").FullText()
 
 	productImagesEl := parsedPage.FindAll("img", "class", "border-dgreyZoom")
@@ -124,6 +140,7 @@ func (ishtari *Ishtari) Index(page data.CrawledPage) {
 	productData := data.Product{
 		Name:        productName,
 		Price:       price,
+		PriceInfo:   priceInfo,
 		Rating:      0,
 		Description: productDescription,
 		URL:         page.URL,
@@ -131,10 +148,7 @@ func (ishtari *Ishtari) Index(page data.CrawledPage) {
 		Images:      productImages,
 	}
 
-	err = ishtari.db.IndexProduct(productData)
-	if utils.HandleErr(err, "Failed to index Ishtari product") {
-		return
-	}
+	return productData, nil
 }
 
 func (ishtari *Ishtari) Sniff(wg *sync.WaitGroup) {
@@ -142,7 +156,10 @@ func (ishtari *Ishtari) Sniff(wg *sync.WaitGroup) {
 
 	defer wg.Done()
 
-	html := utils.FetchPage("https://ishtari.com.gh/", "rod")
+	html, err := utils.FetchPage("https://ishtari.com.gh/", "rod")
+	if utils.HandleErr(err, utils.Sniffer, "Failed to fetch Ishtari home page") {
+		return
+	}
 
 	doc := soup.HTMLParse(html)
 
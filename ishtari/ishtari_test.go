@@ -0,0 +1,11 @@
+package ishtari
+
+import (
+	"testing"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/golden"
+)
+
+func TestExtractGolden(t *testing.T) {
+	golden.Run(t, golden.Dir(source), source, "site", NewIshtari(nil).Extract)
+}
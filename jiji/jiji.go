@@ -3,11 +3,11 @@ package jiji
 import (
 	"fmt"
 	"math/rand"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/Cedi-Search/Cedi-Search-Engine/currency"
 	"github.com/Cedi-Search/Cedi-Search-Engine/data"
 	"github.com/Cedi-Search/Cedi-Search-Engine/database"
 	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
@@ -49,7 +49,7 @@ func queueProducts(db *database.Database, products []soup.Root) {
 		productLink = strings.Split(productLink, "?")[0]
 
 		canQueue, err := db.CanQueueUrl(productLink)
-		if utils.HandleErr(err, "Can't get queue for Jiji ") {
+		if utils.HandleErr(err, utils.Sniffer, "Can't get queue for Jiji ") {
 			return
 		}
 
@@ -59,7 +59,7 @@ func queueProducts(db *database.Database, products []soup.Root) {
 				Source: "Jiji",
 			})
 
-			utils.HandleErr(err, "Failed to add Jiji url to queue")
+			utils.HandleErr(err, utils.Sniffer, "Failed to add Jiji url to queue")
 		} else {
 			utils.Logger(utils.Sniffer, source, "Skipping", productLink)
 		}
@@ -78,7 +78,10 @@ func queueProducts(db *database.Database, products []soup.Root) {
 func extractProducts(href string) []soup.Root {
 	utils.Logger(utils.Sniffer, source, "Extracting products from ", href)
 
-	resp := utils.FetchPage(href, "rod")
+	resp, err := utils.FetchPage(href, "rod")
+	if utils.HandleErr(err, utils.Sniffer, "Failed to fetch Jiji listing") {
+		return []soup.Root{}
+	}
 
 	doc := soup.HTMLParse(resp)
 
@@ -88,13 +91,26 @@ func extractProducts(href string) []soup.Root {
 func (jiji *Jiji) Index(page data.CrawledPage) {
 	utils.Logger(utils.Indexer, source, "Indexing Jiji...")
 
+	productData, err := jiji.Extract(page)
+	if utils.HandleErr(err, utils.Indexer, "Failed to extract Jiji product") {
+		return
+	}
+
+	err = jiji.db.IndexProduct(productData.Fields())
+	if utils.HandleErr(err, utils.Indexer, "Failed to index Jiji product") {
+		return
+	}
+}
+
+// Extract reads a product off a Jiji product page without saving it.
+func (jiji *Jiji) Extract(page data.CrawledPage) (data.Product, error) {
 	parsedPage := soup.HTMLParse(page.HTML)
 
 	// E.g Kia Sorento 2.5 D Automatic 2003 Red in Akuapim South - Cars, Gabriel Sokah | Jiji.com.gh
 	productNameEl := parsedPage.Find("title")
 
 	if productNameEl.Error != nil {
-		return
+		return data.Product{}, productNameEl.Error
 	}
 
 	productName := productNameEl.Text()
@@ -103,20 +119,29 @@ func (jiji *Jiji) Index(page data.CrawledPage) {
 	productPriceEl := parsedPage.Find("div", "itemprop", "price")
 
 	if productPriceEl.Error != nil {
-		return
+		return data.Product{}, productPriceEl.Error
 	}
 
 	productPriceString := productPriceEl.Attrs()["content"]
 
 	if productPriceString == "" {
-		return
+		return data.Product{}, fmt.Errorf("Jiji advert has no price")
 	}
 
-	price, err := strconv.ParseFloat(productPriceString, 64)
-	if utils.HandleErr(err, "Failed to convert Jiji product price") {
-		return
+	// Jiji lists some adverts in dollars; the currency is only given in its own tag.
+	currencyEl := parsedPage.Find("meta", "itemprop", "priceCurrency")
+
+	if currencyEl.Error == nil {
+		productPriceString = currencyEl.Attrs()["content"] + " " + productPriceString
 	}
 
+	priceInfo, err := currency.ParseNormalized(productPriceString)
+	if err != nil {
+		return data.Product{}, fmt.Errorf("failed to convert Jiji product price: %w", err)
+	}
+
+	price := currency.ToMajor(priceInfo.AmountGHS)
+
 	productDescription := parsedPage.Find("span", "class", "qa-description-text").Text()
 
 
@@ -139,6 +164,7 @@ func (jiji *Jiji) Index(page data.CrawledPage) {
 	productData := data.Product{
 		Name:        productName,
 		Price:       price,
+		PriceInfo:   priceInfo,
 		Rating:      0,
 		Description: productDescription,
 		URL:         page.URL,
@@ -146,10 +172,7 @@ func (jiji *Jiji) Index(page data.CrawledPage) {
 		Images:      productImages,
 	}
 
-	err = jiji.db.IndexProduct(productData)
-	if utils.HandleErr(err, "Failed to index Jiji product") {
-		return
-	}
+	return productData, nil
 }
 
 func (jiji *Jiji) Sniff(wg *sync.WaitGroup) {
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Cedi-Search/Cedi-Search-Engine/currency"
 	"github.com/Cedi-Search/Cedi-Search-Engine/data"
 	"github.com/Cedi-Search/Cedi-Search-Engine/database"
 	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
@@ -32,7 +33,7 @@ func queueProducts(db *database.Database, products []soup.Root) {
 		productLink := fmt.Sprintf("https://www.jumia.com.gh%s", link.Attrs()["href"])
 
 		canQueue, err := db.CanQueueUrl(productLink)
-		if utils.HandleErr(err, "Failed to get Jumia queue") {
+		if utils.HandleErr(err, utils.Sniffer, "Failed to get Jumia queue") {
 			return
 		}
 
@@ -42,7 +43,7 @@ func queueProducts(db *database.Database, products []soup.Root) {
 				Source: "Jumia",
 			})
 
-			utils.HandleErr(err, "Failed to add Jumia to queue")
+			utils.HandleErr(err, utils.Sniffer, "Failed to add Jumia to queue")
 		} else {
 			utils.Logger(utils.Sniffer, source, "Skipping", productLink)
 		}
@@ -61,7 +62,10 @@ func queueProducts(db *database.Database, products []soup.Root) {
 func extractProducts(href string) ([]soup.Root, int) {
 	utils.Logger(utils.Sniffer, source, "Extracting products from ", href)
 
-	resp := utils.FetchPage(href, "rod")
+	resp, err := utils.FetchPage(href, "rod")
+	if utils.HandleErr(err, utils.Sniffer, "Failed to fetch Jumia listing") {
+		return []soup.Root{}, 0
+	}
 
 	doc := soup.HTMLParse(resp)
 
@@ -77,7 +81,7 @@ func extractProducts(href string) ([]soup.Root, int) {
 		var err error
 		if len(eqSignSplit) > 1 {
 			totalPages, err = strconv.Atoi(strings.Split(eqSignSplit[1], "#")[0])
-			if utils.HandleErr(err, "Failed to handle Jumia pagination") {
+			if utils.HandleErr(err, utils.Sniffer, "Failed to handle Jumia pagination") {
 				return []soup.Root{}, 0
 			}
 
@@ -96,12 +100,25 @@ func NewJumia(db *database.Database) *Jumia {
 func (jumia *Jumia) Index(page data.CrawledPage) {
 	utils.Logger(utils.Indexer, source, "Indexing Jumia...")
 
+	productData, err := jumia.Extract(page)
+	if utils.HandleErr(err, utils.Indexer, "Failed to extract Jumia product") {
+		return
+	}
+
+	err = jumia.db.IndexProduct(productData.Fields())
+	if utils.HandleErr(err, utils.Indexer, "Failed to index Jumia Product") {
+		return
+	}
+}
+
+// Extract reads a product off a Jumia product page without saving it.
+func (jumia *Jumia) Extract(page data.CrawledPage) (data.Product, error) {
 	parsedPage := soup.HTMLParse(page.HTML)
 
 	productNameEl := parsedPage.Find("h1")
 
 	if productNameEl.Error != nil {
-		return
+		return data.Product{}, productNameEl.Error
 	}
 
 	productName := productNameEl.Text()
@@ -111,16 +128,43 @@ func (jumia *Jumia) Index(page data.CrawledPage) {
 	productPriceStirng := ""
 
 	if productPriceStirngEl.Error != nil {
-		return
+		return data.Product{}, productPriceStirngEl.Error
 	}
 
 	productPriceStirng = productPriceStirngEl.Text()
 
-	priceParts := strings.Split(productPriceStirng, " ")[1]
+	priceInfo, err := currency.ParseNormalized(productPriceStirng)
+	if err != nil {
+		return data.Product{}, fmt.Errorf("failed to parse Jumia product price: %w", err)
+	}
 
-	price, err := strconv.ParseFloat(strings.ReplaceAll(priceParts, ",", ""), 64)
-	if utils.HandleErr(err, "Failed to parse Jumia product price") {
-		return
+	price := currency.ToMajor(priceInfo.AmountGHS)
+
+	// The crossed out price next to the current one, e.g. GH₵ 1,500
+	oldPrice := 0.0
+
+	oldPriceEl := parsedPage.Find("span", "class", "-lthr")
+
+	if oldPriceEl.Error == nil {
+		oldPriceInfo, err := currency.ParseNormalized(oldPriceEl.Text())
+		if err == nil && oldPriceInfo.Currency == priceInfo.Currency {
+			priceInfo.OldAmount = oldPriceInfo.Amount
+			priceInfo.OldAmountGHS = oldPriceInfo.AmountGHS
+			oldPrice = currency.ToMajor(oldPriceInfo.AmountGHS)
+		}
+	}
+
+	// Badges like "Official Store" or "Jumia Express"; the discount badge is computed below instead
+	promotions := []string{}
+
+	for _, el := range parsedPage.FindAll("span", "class", "bdg") {
+		if el.Error != nil || strings.Contains(el.Attrs()["class"], "_dsct") {
+			continue
+		}
+
+		if label := strings.TrimSpace(el.FullText()); label != "" {
+			promotions = append(promotions, label)
+		}
 	}
 
 	productRatingText := parsedPage.Find("div", "class", "stars").Text()
@@ -128,8 +172,8 @@ func (jumia *Jumia) Index(page data.CrawledPage) {
 	productRatingString := strings.Split(productRatingText, " ")[0]
 
 	rating, err := strconv.ParseFloat(productRatingString, 64)
-	if utils.HandleErr(err, "Failed to parse Jumia product rating") {
-		return
+	if err != nil {
+		return data.Product{}, fmt.Errorf("failed to parse Jumia product rating: %w", err)
 	}
 
 	productDescriptionEl := parsedPage.Find("div", "class", "-mhm")
@@ -149,19 +193,20 @@ func (jumia *Jumia) Index(page data.CrawledPage) {
 	}
 
 	productData := data.Product{
-		Name:        productName,
-		Price:       price,
-		Rating:      rating,
-		Description: productDescription,
-		URL:         page.URL,
-		Source:      page.Source,
-		Images:      productImages,
+		Name:            productName,
+		Price:           price,
+		PriceInfo:       priceInfo,
+		OldPrice:        oldPrice,
+		DiscountPercent: currency.DiscountPercent(price, oldPrice),
+		Promotions:      promotions,
+		Rating:          rating,
+		Description:     productDescription,
+		URL:             page.URL,
+		Source:          page.Source,
+		Images:          productImages,
 	}
 
-	err = jumia.db.IndexProduct(productData)
-	if utils.HandleErr(err, "Failed to index Jumia Product") {
-		return
-	}
+	return productData, nil
 }
 
 func (jumia *Jumia) Sniff(wg *sync.WaitGroup) {
@@ -169,7 +214,10 @@ func (jumia *Jumia) Sniff(wg *sync.WaitGroup) {
 
 	defer wg.Done()
 
-	resp := utils.FetchPage("https://www.jumia.com.gh", "rod")
+	resp, err := utils.FetchPage("https://www.jumia.com.gh", "rod")
+	if utils.HandleErr(err, utils.Sniffer, "Failed to fetch Jumia home page") {
+		return
+	}
 
 	doc := soup.HTMLParse(resp)
 
@@ -1,41 +1,246 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"os"
 	"sync"
+	"time"
 
+	"github.com/Cedi-Search/Cedi-Search-Engine/admin"
+	"github.com/Cedi-Search/Cedi-Search-Engine/alerts"
+	"github.com/Cedi-Search/Cedi-Search-Engine/commands"
 	"github.com/Cedi-Search/Cedi-Search-Engine/config"
 	"github.com/Cedi-Search/Cedi-Search-Engine/crawler"
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
 	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+	"github.com/Cedi-Search/Cedi-Search-Engine/health"
+	"github.com/Cedi-Search/Cedi-Search-Engine/matcher"
+	"github.com/Cedi-Search/Cedi-Search-Engine/metrics"
+	"github.com/Cedi-Search/Cedi-Search-Engine/scheduler"
 	"github.com/Cedi-Search/Cedi-Search-Engine/sniffer"
+	"github.com/Cedi-Search/Cedi-Search-Engine/targets"
+	"github.com/Cedi-Search/Cedi-Search-Engine/tracing"
 	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
 	"github.com/anaskhan96/soup"
 	"github.com/joho/godotenv"
 )
 
 func main() {
-	utils.Logger(utils.Default, utils.Default, "Startup")
-
 	soup.Header("User-Agent", config.USER_AGENT)
 
-	wg := sync.WaitGroup{}
-
 	godotenv.Load()
 
+	logConfig, err := utils.LogConfigFromEnv()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	utils.SetupLogging(logConfig)
+
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	defer shutdownTracing(context.Background())
+
+	utils.Log(utils.Default).Info("Startup")
+
 	db := database.NewDatabase()
 
+	err = db.EnsureTargetIndexes()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	err = db.EnsureDeadLetterIndexes()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "admin":
+			err = serveAdmin(db)
+		case "reindex":
+			err = commands.Reindex(db, os.Args[2:])
+		case "price-history":
+			err = commands.PriceHistory(db, os.Args[2:])
+		case "deals":
+			err = commands.Deals(db, os.Args[2:])
+		case "configure-search":
+			err = commands.ConfigureSearch(db, os.Args[2:])
+		case "recrawl-stats":
+			err = commands.RecrawlStats(db, os.Args[2:])
+		case "validate-targets":
+			err = commands.ValidateTargets(db, os.Args[2:])
+		case "extract":
+			err = commands.Extract(db, os.Args[2:])
+		case "extraction-health":
+			err = commands.ExtractionHealth(db, os.Args[2:])
+		case "cache":
+			err = commands.Cache(db, os.Args[2:])
+		case "dead-letters":
+			err = commands.DeadLetters(db, os.Args[2:])
+		case "blocked":
+			err = commands.Blocked(db, os.Args[2:])
+		case "proxy-stats":
+			err = commands.ProxyStats(db, os.Args[2:])
+		case "match":
+			err = commands.Match(db, os.Args[2:])
+		case "subscribe":
+			err = commands.Subscribe(db, os.Args[2:])
+		case "unsubscribe":
+			err = commands.Unsubscribe(db, os.Args[2:])
+		case "subscriptions":
+			err = commands.Subscriptions(db, os.Args[2:])
+		default:
+			log.Fatalln("Unknown command:", os.Args[1])
+		}
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
+	run(db)
+}
+
+// newAlertEvaluator sets up price alerts with every notifier we can configure.
+func newAlertEvaluator(db *database.Database) *alerts.Evaluator {
+	evaluator := alerts.NewEvaluator(db)
+
+	evaluator.Register("webhook", alerts.NewWebhookNotifier())
+
+	if smtpNotifier := alerts.NewSMTPNotifierFromEnv(); smtpNotifier != nil {
+		evaluator.Register("email", smtpNotifier)
+	}
+
+	return evaluator
+}
+
+// run sniffs and crawls every target until the process is stopped.
+func run(db *database.Database) {
+	wg := sync.WaitGroup{}
+
+	// Only live crawls alert; commands like reindex replay old prices.
+	db.AddPriceObserver(newAlertEvaluator(db))
+
+	migrated, duplicates, err := db.MigrateProductIDs()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if migrated > 0 || duplicates > 0 {
+		utils.Log(utils.Database).Info("Migrated legacy products", "migrated", migrated, "duplicates", duplicates)
+	}
+
 	crawlerFunc := crawler.NewCrawler(db)
 
-	targets, err := db.GetTargets()
+	monitor := health.NewMonitor(db)
+	crawlerFunc.AddExtractionObserver(monitor)
+
+	all, err := targets.Load(db)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	wg.Add(len(targets))
-	for _, target := range targets {
+	wg.Add(len(all))
+	for _, target := range all {
+		for _, err := range utils.AssignProxies(target.Host, target.Proxies) {
+			utils.Log(utils.Crawler).Error("Invalid proxy", "source", target.Target, "error", err)
+		}
+
 		go sniffer.Sniff(target, db)
-		go crawlerFunc.Crawl(target)
+		go crawlerFunc.Run(target)
+		go scheduler.Schedule(target, db)
+	}
+
+	go serveMetrics(db, all)
+
+	go monitor.Run()
+
+	if os.Getenv("ADMIN_TOKEN") != "" {
+		go func() {
+			err := serveAdmin(db)
+			utils.HandleErr(err, utils.Default, "Failed to serve admin API")
+		}()
 	}
 
+	go func() {
+		for {
+			time.Sleep(config.PROXY_CHECK_INTERVAL)
+
+			utils.CheckBenchedProxies()
+		}
+	}()
+
+	go func() {
+		for {
+			time.Sleep(config.PROXY_STATS_FLUSH)
+
+			err := db.RecordProxyStats(utils.TakeProxyStats())
+			utils.HandleErr(err, utils.Database, "Failed to record proxy stats")
+		}
+	}()
+
+	go func() {
+		for {
+			time.Sleep(config.MATCH_INTERVAL)
+
+			_, err := matcher.Match(db, true)
+			utils.HandleErr(err, utils.Matcher, "Failed to match products")
+		}
+	}()
+
 	wg.Wait()
 }
+
+// serveMetrics exposes Prometheus metrics on METRICS_ADDR (default :9090)
+// and keeps the queue depth of every target up to date.
+func serveMetrics(db *database.Database, all []data.Target) {
+	go func() {
+		for {
+			for _, target := range all {
+				depth, err := db.CountQueue(target.Target)
+				if !utils.HandleErr(err, utils.Database, "Failed to count queue", "source", target.Target) {
+					metrics.QueueDepth.WithLabelValues(target.Target).Set(float64(depth))
+				}
+			}
+
+			time.Sleep(config.METRICS_QUEUE_INTERVAL)
+		}
+	}()
+
+	addr := os.Getenv("METRICS_ADDR")
+	if addr == "" {
+		addr = ":9090"
+	}
+
+	utils.Log(utils.Default).Info("Serving metrics", "addr", addr)
+
+	err := metrics.Serve(addr)
+	utils.HandleErr(err, utils.Default, "Failed to serve metrics")
+}
+
+// serveAdmin serves the admin API for targets on ADMIN_ADDR (default :8081),
+// authenticated with ADMIN_TOKEN.
+func serveAdmin(db *database.Database) error {
+	server, err := admin.NewServer(db, os.Getenv("ADMIN_TOKEN"))
+	if err != nil {
+		return err
+	}
+
+	addr := os.Getenv("ADMIN_ADDR")
+	if addr == "" {
+		addr = ":8081"
+	}
+
+	utils.Log(utils.Default).Info("Serving admin API", "addr", addr)
+
+	return http.ListenAndServe(addr, server)
+}
@@ -0,0 +1,98 @@
+package matcher
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"net/http"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/config"
+)
+
+var imageClient = &http.Client{Timeout: 30 * time.Second}
+
+// ImageHash downloads an image and returns its difference hash (dHash).
+//
+// The image is shrunk to 9x8 grey levels and each bit records whether a pixel
+// is brighter than its right neighbour, so resized or recompressed copies of
+// the same product photo hash to (nearly) the same value.
+func ImageHash(href string) (uint64, error) {
+	req, err := http.NewRequest(http.MethodGet, href, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("User-Agent", config.USER_AGENT)
+
+	res, err := imageClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("image %s responded with %s", href, res.Status)
+	}
+
+	img, _, err := image.Decode(res.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	return dHash(img), nil
+}
+
+// dHash computes the difference hash of an image.
+func dHash(img image.Image) uint64 {
+	const width, height = 9, 8
+
+	bounds := img.Bounds()
+
+	grey := [height][width]float64{}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// Average the block of source pixels that maps to this cell.
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/width
+			y0 := bounds.Min.Y + y*bounds.Dy()/height
+			y1 := bounds.Min.Y + (y+1)*bounds.Dy()/height
+
+			total, count := 0.0, 0
+
+			for sy := y0; sy < y1 || sy == y0; sy++ {
+				for sx := x0; sx < x1 || sx == x0; sx++ {
+					r, g, b, _ := img.At(sx, sy).RGBA()
+					total += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					count++
+				}
+			}
+
+			grey[y][x] = total / float64(count)
+		}
+	}
+
+	var hash uint64
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width-1; x++ {
+			hash <<= 1
+
+			if grey[y][x] > grey[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// hammingDistance counts the bits two hashes differ in.
+func hammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,502 @@
+package matcher
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// Two images whose hashes differ in at most this many bits are the same photo.
+	maxImageDistance = 5
+	// Products of the same brand whose names share at least this fraction of words are the same.
+	minNameSimilarity = 0.8
+)
+
+// knownBrands maps words found in product names to the brand they imply.
+var knownBrands = map[string]string{
+	"apple": "apple", "iphone": "apple", "ipad": "apple", "macbook": "apple", "airpods": "apple",
+	"samsung": "samsung", "galaxy": "samsung",
+	"tecno": "tecno", "infinix": "infinix", "itel": "itel", "oraimo": "oraimo",
+	"xiaomi": "xiaomi", "redmi": "xiaomi", "poco": "xiaomi",
+	"nokia": "nokia", "huawei": "huawei", "oppo": "oppo", "vivo": "vivo", "realme": "realme",
+	"hp": "hp", "lenovo": "lenovo", "dell": "dell", "asus": "asus", "acer": "acer",
+	"lg": "lg", "sony": "sony", "hisense": "hisense", "tcl": "tcl", "nasco": "nasco",
+	"binatone": "binatone", "midea": "midea", "anker": "anker", "jbl": "jbl", "canon": "canon",
+	"epson": "epson", "philips": "philips",
+}
+
+var (
+	wordRe     = regexp.MustCompile(`[a-z0-9]+(?:-[a-z0-9]+)*`)
+	variantRe  = regexp.MustCompile(`^\d+(\.\d+)?(gb|tb|mb|mah|w|mm|cm|ml|l|kg|g|inch|hz|mp|v)$`)
+	hasDigitRe = regexp.MustCompile(`\d`)
+	hasAlphaRe = regexp.MustCompile(`[a-z]`)
+	gtinRe     = regexp.MustCompile(`^\d{8,14}$`)
+)
+
+// features are what products are compared on.
+type features struct {
+	id       string
+	source   string
+	name     string
+	url      string
+	image    string
+	price    float64
+	words    map[string]bool
+	brand    string
+	models   []string
+	variants []string
+	gtin     string
+
+	imageHash    uint64
+	hasImageHash bool
+}
+
+// extractFeatures normalises an indexed product into comparable features.
+func extractFeatures(product map[string]interface{}) features {
+	f := features{
+		words: map[string]bool{},
+	}
+
+	f.id, _ = product["_id"].(string)
+	f.source, _ = product["source"].(string)
+	f.name, _ = product["name"].(string)
+	f.name = strings.Join(strings.Fields(f.name), " ")
+	f.url, _ = product["url"].(string)
+	f.price, _ = product["price"].(float64)
+
+	if images := toStrings(product["images"]); len(images) > 0 {
+		f.image = images[0]
+	}
+
+	if hash, ok := product["image_hash"].(string); ok && hash != "" {
+		value, err := strconv.ParseUint(hash, 16, 64)
+		if err == nil {
+			f.imageHash = value
+			f.hasImageHash = true
+		}
+	}
+
+	for _, word := range wordRe.FindAllString(strings.ToLower(f.name), -1) {
+		f.words[word] = true
+
+		if f.brand == "" {
+			f.brand = knownBrands[word]
+		}
+
+		compact := strings.ReplaceAll(word, "-", "")
+
+		switch {
+		case variantRe.MatchString(compact):
+			f.variants = append(f.variants, compact)
+		case hasDigitRe.MatchString(compact) && hasAlphaRe.MatchString(compact) && len(compact) >= 3:
+			f.models = append(f.models, compact)
+		}
+	}
+
+	if brand, ok := product["brand"].(string); ok && brand != "" {
+		f.brand = strings.ToLower(strings.TrimSpace(brand))
+	}
+
+	f.gtin = gtinOf(product)
+
+	return f
+}
+
+// toStrings reads a list of strings out of a product field, whether it
+// was set by the indexer or decoded from the database.
+func toStrings(value interface{}) []string {
+	switch values := value.(type) {
+	case []string:
+		return values
+	case primitive.A:
+		return toStrings([]interface{}(values))
+	case []interface{}:
+		strs := []string{}
+
+		for _, item := range values {
+			if str, ok := item.(string); ok {
+				strs = append(strs, str)
+			}
+		}
+
+		return strs
+	}
+
+	return []string{}
+}
+
+// gtinOf finds a product's GTIN/EAN, either as an extracted field
+// or in the query of its URL, e.g. Oraimo's ?ean=4894947008030.
+func gtinOf(product map[string]interface{}) string {
+	for _, key := range []string{"gtin", "ean"} {
+		if value, ok := product[key].(string); ok && gtinRe.MatchString(strings.TrimSpace(value)) {
+			return strings.TrimSpace(value)
+		}
+	}
+
+	href, _ := product["url"].(string)
+
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+
+	for _, key := range []string{"ean", "gtin", "barcode"} {
+		if value := u.Query().Get(key); gtinRe.MatchString(value) {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// sameProduct decides whether two products are the same item.
+func sameProduct(a, b features) bool {
+	if a.gtin != "" && b.gtin != "" {
+		return a.gtin == b.gtin
+	}
+
+	if a.brand != "" && b.brand != "" && a.brand != b.brand {
+		return false
+	}
+
+	if !variantsAgree(a.variants, b.variants) {
+		return false
+	}
+
+	similarity := nameSimilarity(a.words, b.words)
+
+	if a.hasImageHash && b.hasImageHash && hammingDistance(a.imageHash, b.imageHash) <= maxImageDistance && similarity >= 0.5 {
+		return true
+	}
+
+	if a.brand == "" || b.brand == "" {
+		return false
+	}
+
+	if shareAny(a.models, b.models) {
+		return true
+	}
+
+	return len(a.models) == 0 && len(b.models) == 0 && similarity >= minNameSimilarity
+}
+
+// variantsAgree reports whether two products have no conflicting
+// capacities, e.g. a 128gb and a 256gb phone are different products.
+func variantsAgree(a, b []string) bool {
+	for _, left := range a {
+		unit := strings.TrimLeft(left, "0123456789.")
+
+		for _, right := range b {
+			if strings.TrimLeft(right, "0123456789.") == unit && left != right {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// nameSimilarity is the Jaccard similarity of two sets of words.
+func nameSimilarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+
+	for word := range a {
+		if b[word] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func shareAny(a, b []string) bool {
+	for _, left := range a {
+		for _, right := range b {
+			if left == right {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Cluster groups products that are the same item.
+//
+// Products are only compared within blocks of plausible matches, see
+// blockingKeys, and GTINs link products across blocks.
+func Cluster(products []map[string]interface{}) []data.ProductGroup {
+	all := make([]features, len(products))
+	for i, product := range products {
+		all[i] = extractFeatures(product)
+	}
+
+	parent := make([]int, len(all))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+
+		return parent[i]
+	}
+
+	union := func(i, j int) {
+		parent[find(i)] = find(j)
+	}
+
+	byGTIN := map[string]int{}
+	blocks := map[string][]int{}
+
+	for i, f := range all {
+		if f.gtin != "" {
+			if j, found := byGTIN[f.gtin]; found {
+				union(i, j)
+			} else {
+				byGTIN[f.gtin] = i
+			}
+		}
+
+		for _, key := range blockingKeys(f) {
+			blocks[key] = append(blocks[key], i)
+		}
+	}
+
+	for _, block := range blocks {
+		for x := 0; x < len(block); x++ {
+			for y := x + 1; y < len(block); y++ {
+				if find(block[x]) != find(block[y]) && sameProduct(all[block[x]], all[block[y]]) {
+					union(block[x], block[y])
+				}
+			}
+		}
+	}
+
+	members := map[int][]features{}
+	for i, f := range all {
+		root := find(i)
+		members[root] = append(members[root], f)
+	}
+
+	groups := []data.ProductGroup{}
+	for _, group := range members {
+		groups = append(groups, newGroup(group))
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+
+	return groups
+}
+
+// blockingKeys lists the blocks a product is compared within, one for every way
+// sameProduct can match it to another product, besides the GTIN:
+//
+//   - every model token of a branded product, e.g. "model:a54";
+//   - the brand of a branded product without model tokens, e.g. "brand:nasco";
+//   - every chunk of the image hash, e.g. "image:2:1f3". Hashes within
+//     maxImageDistance bits of each other differ in at most that many chunks,
+//     so with one chunk more they share at least one.
+//
+// Products with neither a brand nor an image hash are in no block at all.
+func blockingKeys(f features) []string {
+	keys := []string{}
+
+	if f.brand != "" {
+		for _, model := range f.models {
+			keys = append(keys, "model:"+model)
+		}
+
+		if len(f.models) == 0 {
+			keys = append(keys, "brand:"+f.brand)
+		}
+	}
+
+	if f.hasImageHash {
+		chunks := maxImageDistance + 1
+		size := (64 + chunks - 1) / chunks
+
+		for i := 0; i < chunks; i++ {
+			chunk := (f.imageHash >> (i * size)) & (1<<size - 1)
+			keys = append(keys, fmt.Sprintf("image:%d:%x", i, chunk))
+		}
+	}
+
+	return keys
+}
+
+// newGroup builds a canonical product from the products in a cluster.
+//
+// The group ID is derived from the smallest product ID in it, so it stays
+// the same between runs as long as that product stays in the group.
+func newGroup(members []features) data.ProductGroup {
+	sort.Slice(members, func(i, j int) bool { return members[i].id < members[j].id })
+
+	sum := sha1.Sum([]byte(members[0].id))
+
+	group := data.ProductGroup{
+		ID:        hex.EncodeToString(sum[:])[:16],
+		Offers:    []data.Offer{},
+		Models:    []string{},
+		UpdatedAt: time.Now(),
+	}
+
+	stores := map[string]bool{}
+	models := map[string]bool{}
+
+	for _, member := range members {
+		group.Offers = append(group.Offers, data.Offer{
+			ProductID: member.id,
+			Source:    member.source,
+			Name:      member.name,
+			URL:       member.url,
+			Price:     member.price,
+			Image:     member.image,
+		})
+
+		stores[member.source] = true
+
+		if len(member.name) > len(group.Name) {
+			group.Name = member.name
+		}
+
+		if group.Brand == "" {
+			group.Brand = member.brand
+		}
+
+		if group.GTIN == "" {
+			group.GTIN = member.gtin
+		}
+
+		for _, model := range member.models {
+			if !models[model] {
+				models[model] = true
+				group.Models = append(group.Models, model)
+			}
+		}
+
+		if member.price > 0 && (group.MinPrice == 0 || member.price < group.MinPrice) {
+			group.MinPrice = member.price
+		}
+	}
+
+	// Stable, so offers at the same price keep their order between runs.
+	sort.SliceStable(group.Offers, func(i, j int) bool { return group.Offers[i].Price < group.Offers[j].Price })
+
+	group.Stores = len(stores)
+	group.Summary = summary(group)
+
+	return group
+}
+
+// summary describes a group the way search shows it, e.g. "3 stores, from GH₵ 1,150".
+func summary(group data.ProductGroup) string {
+	stores := fmt.Sprintf("%d stores", group.Stores)
+	if group.Stores == 1 {
+		stores = "1 store"
+	}
+
+	if group.MinPrice == 0 {
+		return stores
+	}
+
+	return fmt.Sprintf("%s, from GH₵ %s", stores, formatAmount(group.MinPrice))
+}
+
+// formatAmount formats an amount with thousands separators, dropping zero pesewas.
+func formatAmount(amount float64) string {
+	whole := int64(amount)
+	pesewas := int64((amount-float64(whole))*100 + 0.5)
+
+	digits := strconv.FormatInt(whole, 10)
+
+	var formatted strings.Builder
+
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			formatted.WriteRune(',')
+		}
+
+		formatted.WriteRune(digit)
+	}
+
+	if pesewas > 0 {
+		fmt.Fprintf(&formatted, ".%02d", pesewas)
+	}
+
+	return formatted.String()
+}
+
+// Match clusters every indexed product and saves the resulting groups.
+//
+// When hashImages is set, products without an image hash get one computed
+// from their first image before clustering.
+func Match(db *database.Database, hashImages bool) ([]data.ProductGroup, error) {
+	utils.Log(utils.Matcher).Info("Matching products")
+
+	products, err := db.GetAllProducts()
+	if err != nil {
+		return nil, err
+	}
+
+	if hashImages {
+		for _, product := range products {
+			id, ok := product["_id"].(string)
+			if !ok {
+				continue
+			}
+
+			if hash, ok := product["image_hash"].(string); ok && hash != "" {
+				continue
+			}
+
+			images := toStrings(product["images"])
+			if len(images) == 0 || images[0] == "" {
+				continue
+			}
+
+			image := images[0]
+
+			hash, err := ImageHash(image)
+			if utils.HandleErr(err, utils.Matcher, "Failed to hash image", "image", image) {
+				continue
+			}
+
+			product["image_hash"] = strconv.FormatUint(hash, 16)
+
+			err = db.SetImageHash(id, product["image_hash"].(string))
+			utils.HandleErr(err, utils.Matcher, "Failed to save image hash", "product", id)
+		}
+	}
+
+	groups := Cluster(products)
+
+	err = db.SaveProductGroups(groups)
+	if err != nil {
+		return groups, err
+	}
+
+	utils.Log(utils.Matcher).Info("Matched products", "products", len(products), "groups", len(groups))
+
+	return groups, nil
+}
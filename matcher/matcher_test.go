@@ -0,0 +1,233 @@
+package matcher
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func product(id, source, name string, fields ...string) map[string]interface{} {
+	p := map[string]interface{}{
+		"_id":    id,
+		"source": source,
+		"name":   name,
+		"url":    id,
+	}
+
+	for i := 0; i+1 < len(fields); i += 2 {
+		p[fields[i]] = fields[i+1]
+	}
+
+	return p
+}
+
+func TestSameProduct(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b map[string]interface{}
+		want bool
+	}{
+		{
+			name: "same GTIN",
+			a:    product("a", "Jumia", "Oraimo FreePods 4", "gtin", "4894947008030"),
+			b:    product("b", "Oraimo", "FreePods 4 ANC earbuds", "gtin", "4894947008030"),
+			want: true,
+		},
+		{
+			name: "different GTINs win over the same name",
+			a:    product("a", "Jumia", "Oraimo FreePods 4", "gtin", "4894947008030"),
+			b:    product("b", "Oraimo", "Oraimo FreePods 4", "gtin", "4894947008047"),
+			want: false,
+		},
+		{
+			name: "shared model token",
+			a:    product("a", "Jumia", "Samsung Galaxy A54 5G 128GB"),
+			b:    product("b", "Jiji", "Galaxy A54 128GB black, new"),
+			want: true,
+		},
+		{
+			name: "conflicting capacity",
+			a:    product("a", "Jumia", "Samsung Galaxy A54 128GB"),
+			b:    product("b", "Jiji", "Samsung Galaxy A54 256GB"),
+			want: false,
+		},
+		{
+			name: "different brands",
+			a:    product("a", "Jumia", "Tecno Spark 10 Pro"),
+			b:    product("b", "Jiji", "Infinix Spark 10 Pro"),
+			want: false,
+		},
+		{
+			name: "same brand and name without models",
+			a:    product("a", "Ishtari", "Nasco Blender 1.5L"),
+			b:    product("b", "Jumia", "nasco blender 1.5l"),
+			want: true,
+		},
+		{
+			name: "same brand, different names without models",
+			a:    product("a", "Ishtari", "Nasco Blender 1.5L"),
+			b:    product("b", "Jumia", "Nasco Standing Fan"),
+			want: false,
+		},
+		{
+			name: "no brand and no image",
+			a:    product("a", "Jiji", "Blender for sale"),
+			b:    product("b", "Deus", "Blender for sale"),
+			want: false,
+		},
+		{
+			name: "no brand, near-identical image",
+			a:    product("a", "Jiji", "Glass blender 1.5L", "image_hash", "f0f0f0f0f0f0f0f0"),
+			b:    product("b", "Deus", "Glass blender 1.5L silver", "image_hash", "f0f0f0f0f0f0f0f3"),
+			want: true,
+		},
+		{
+			name: "no brand, different image",
+			a:    product("a", "Jiji", "Glass blender 1.5L", "image_hash", "f0f0f0f0f0f0f0f0"),
+			b:    product("b", "Deus", "Glass blender 1.5L silver", "image_hash", "0f0f0f0f0f0f0f0f"),
+			want: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, b := extractFeatures(test.a), extractFeatures(test.b)
+
+			if got := sameProduct(a, b); got != test.want {
+				t.Errorf("sameProduct = %v, want %v", got, test.want)
+			}
+
+			if got := sameProduct(b, a); got != test.want {
+				t.Errorf("sameProduct reversed = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestVariantsAgree(t *testing.T) {
+	tests := []struct {
+		a, b []string
+		want bool
+	}{
+		{nil, nil, true},
+		{[]string{"128gb"}, nil, true},
+		{[]string{"128gb"}, []string{"128gb"}, true},
+		{[]string{"128gb"}, []string{"256gb"}, false},
+		{[]string{"128gb", "5000mah"}, []string{"128gb", "6000mah"}, false},
+		{[]string{"128gb"}, []string{"5000mah"}, true},
+		{[]string{"1.5l"}, []string{"1.5l"}, true},
+		{[]string{"1.5l"}, []string{"2l"}, false},
+	}
+
+	for _, test := range tests {
+		if got := variantsAgree(test.a, test.b); got != test.want {
+			t.Errorf("variantsAgree(%v, %v) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   string
+	}{
+		{0, "0"},
+		{5, "5"},
+		{999, "999"},
+		{1000, "1,000"},
+		{1150, "1,150"},
+		{1234567, "1,234,567"},
+		{2199.5, "2,199.50"},
+		{19.99, "19.99"},
+		{0.05, "0.05"},
+	}
+
+	for _, test := range tests {
+		if got := formatAmount(test.amount); got != test.want {
+			t.Errorf("formatAmount(%v) = %q, want %q", test.amount, got, test.want)
+		}
+	}
+}
+
+func TestBlockingKeysCatchNearImages(t *testing.T) {
+	hash := uint64(0x0123456789abcdef)
+
+	// Flip up to maxImageDistance bits spread over the hash; the two must still share a block.
+	for flips := 0; flips <= maxImageDistance; flips++ {
+		other := hash
+		for i := 0; i < flips; i++ {
+			other ^= 1 << (i * 13)
+		}
+
+		a := extractFeatures(product("a", "Jiji", "Blender", "image_hash", strconv.FormatUint(hash, 16)))
+		b := extractFeatures(product("b", "Deus", "Blender", "image_hash", strconv.FormatUint(other, 16)))
+
+		if !shareAny(blockingKeys(a), blockingKeys(b)) {
+			t.Errorf("hashes %d bits apart share no block", flips)
+		}
+	}
+
+	unbranded := extractFeatures(product("a", "Jiji", "Blender for sale"))
+	if keys := blockingKeys(unbranded); len(keys) != 0 {
+		t.Errorf("a product without brand or image hash is in blocks %v", keys)
+	}
+}
+
+func TestCluster(t *testing.T) {
+	products := []map[string]interface{}{
+		product("jumia/a54", "Jumia", "Samsung Galaxy A54 5G 128GB"),
+		product("jiji/a54", "Jiji", "Galaxy A54 128GB black, new"),
+		product("jiji/a54-256", "Jiji", "Samsung Galaxy A54 256GB"),
+		product("jumia/freepods", "Jumia", "Oraimo FreePods 4", "gtin", "4894947008030"),
+		product("oraimo/freepods", "Oraimo", "FreePods 4 ANC", "gtin", "4894947008030"),
+		product("jiji/blender", "Jiji", "Blender for sale"),
+		product("deus/blender", "Deus", "Blender for sale"),
+	}
+
+	groups := Cluster(products)
+
+	got := [][]string{}
+	for _, group := range groups {
+		ids := []string{}
+		for _, offer := range group.Offers {
+			ids = append(ids, offer.ProductID)
+		}
+
+		sort.Strings(ids)
+		got = append(got, ids)
+	}
+
+	sort.Slice(got, func(i, j int) bool { return got[i][0] < got[j][0] })
+
+	want := [][]string{
+		{"deus/blender"},
+		{"jiji/a54", "jumia/a54"},
+		{"jiji/a54-256"},
+		{"jiji/blender"},
+		{"jumia/freepods", "oraimo/freepods"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got groups %v, want %v", got, want)
+	}
+
+	for i := range want {
+		if len(got[i]) != len(want[i]) {
+			t.Fatalf("got groups %v, want %v", got, want)
+		}
+
+		for j := range want[i] {
+			if got[i][j] != want[i][j] {
+				t.Fatalf("got groups %v, want %v", got, want)
+			}
+		}
+	}
+
+	// Group IDs stay the same between runs.
+	again := Cluster(products)
+	for i := range groups {
+		if groups[i].ID != again[i].ID {
+			t.Errorf("group %d has ID %s, then %s", i, groups[i].ID, again[i].ID)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// SniffedLinks counts the links the sniffer classified, by class.
+	SniffedLinks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cedi_sniffer_links_total",
+		Help: "Links found by the sniffer, by source and class.",
+	}, []string{"source", "class"})
+
+	// SniffedProducts counts the product links the sniffer queued or skipped.
+	SniffedProducts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cedi_sniffer_products_total",
+		Help: "Product links found by the sniffer, by source and whether they were queued or skipped.",
+	}, []string{"source", "result"})
+
+	// FetchDuration observes how long fetches take, by fetcher and status.
+	FetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cedi_fetch_duration_seconds",
+		Help:    "Page fetch latency, by source host, fetcher and status code.",
+		Buckets: []float64{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60},
+	}, []string{"host", "fetcher", "status"})
+
+	// ExtractionFailures counts the fields the indexer couldn't extract.
+	ExtractionFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cedi_extraction_failures_total",
+		Help: "Fields the indexer failed to extract, by source, field and reason.",
+	}, []string{"source", "field", "reason"})
+
+	// FillRate is the share of a source's latest pages a field was extracted from.
+	FillRate = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cedi_extraction_fill_rate",
+		Help: "Share of the latest pages a field was extracted from, by source and field.",
+	}, []string{"source", "field"})
+
+	// IndexedProducts counts indexed products by whether they were new, updated or unchanged.
+	IndexedProducts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cedi_indexed_products_total",
+		Help: "Products indexed, by source and result (new, updated or unchanged).",
+	}, []string{"source", "result"})
+
+	// QueueDepth is the number of URLs in url_queues.
+	QueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cedi_queue_depth",
+		Help: "URLs waiting in the crawl queue, by source.",
+	}, []string{"source"})
+
+	// ProxyRequests counts the fetches through each proxy by outcome.
+	ProxyRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cedi_proxy_requests_total",
+		Help: "Fetches through a proxy, by proxy and outcome (success, failure or blocked).",
+	}, []string{"proxy", "outcome"})
+
+	// Browsers is the number of headless browsers running.
+	Browsers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cedi_browsers",
+		Help: "Headless browsers running, one per proxy plus the direct one.",
+	})
+
+	// BrowserPages is the number of browser pages open.
+	BrowserPages = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cedi_browser_pages",
+		Help: "Browser pages currently fetching.",
+	})
+)
+
+// Serve exposes the metrics on /metrics at addr, e.g. ":9090".
+func Serve(addr string) error {
+	mux := http.NewServeMux()
+
+	mux.Handle("/metrics", promhttp.Handler())
+
+	return http.ListenAndServe(addr, mux)
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Cedi-Search/Cedi-Search-Engine/currency"
 	"github.com/Cedi-Search/Cedi-Search-Engine/data"
 	"github.com/Cedi-Search/Cedi-Search-Engine/database"
 	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
@@ -43,7 +44,7 @@ func queueProducts(db *database.Database, products []soup.Root) {
 		fmtedProductLink := fmt.Sprintf("https://gh.oraimo.com%s", strings.Split(productLink, "?")[0])
 
 		canQueue, err := db.CanQueueUrl(fmtedProductLink)
-		if utils.HandleErr(err, "Failed to get Oraimo queue") {
+		if utils.HandleErr(err, utils.Sniffer, "Failed to get Oraimo queue") {
 			return
 		}
 
@@ -53,7 +54,7 @@ func queueProducts(db *database.Database, products []soup.Root) {
 				Source: "Oraimo",
 			})
 
-			utils.HandleErr(err, "Failed to add Oraimo to queue")
+			utils.HandleErr(err, utils.Sniffer, "Failed to add Oraimo to queue")
 		} else {
 			utils.Logger(utils.Sniffer, source, "Skipping", fmtedProductLink)
 		}
@@ -72,7 +73,10 @@ func queueProducts(db *database.Database, products []soup.Root) {
 func extractProducts(href string) []soup.Root {
 	utils.Logger(utils.Sniffer, source, "Extracting products from ", href)
 
-	resp := utils.FetchPage(href, "rod")
+	resp, err := utils.FetchPage(href, "rod")
+	if utils.HandleErr(err, utils.Sniffer, "Failed to fetch Oraimo listing") {
+		return []soup.Root{}
+	}
 
 	doc := soup.HTMLParse(resp)
 
@@ -82,6 +86,19 @@ func extractProducts(href string) []soup.Root {
 func (oraimo *Oraimo) Index(page data.CrawledPage) {
 	utils.Logger(utils.Indexer, source, "Indexing Oraimo...")
 
+	productData, err := oraimo.Extract(page)
+	if utils.HandleErr(err, utils.Indexer, "Failed to extract Oraimo product") {
+		return
+	}
+
+	err = oraimo.db.IndexProduct(productData.Fields())
+	if utils.HandleErr(err, utils.Indexer, "Failed to index Oraimo product") {
+		return
+	}
+}
+
+// Extract reads a product off an Oraimo product page without saving it.
+func (oraimo *Oraimo) Extract(page data.CrawledPage) (data.Product, error) {
 	parsedPage := soup.HTMLParse(page.HTML)
 
 	productName := parsedPage.Find("h1").FullText()
@@ -89,13 +106,14 @@ func (oraimo *Oraimo) Index(page data.CrawledPage) {
 	productName = strings.Trim(productName, " ")
 
 	productPriceStirng := parsedPage.Find("span", "class", "price").Text()
-	productPriceStirng = strings.ReplaceAll(productPriceStirng, "₵", "")
 
-	price, err := strconv.ParseFloat(strings.ReplaceAll(productPriceStirng, ",", ""), 64)
-	if utils.HandleErr(err, "Failed to parse Oraimo product price") {
-		return
+	priceInfo, err := currency.ParseNormalized(productPriceStirng)
+	if err != nil {
+		return data.Product{}, fmt.Errorf("failed to parse Oraimo product price: %w", err)
 	}
 
+	price := currency.ToMajor(priceInfo.AmountGHS)
+
 	rating := 0.0
 
 	ratingEl := parsedPage.Find("div", "class", "rating-result")
@@ -105,8 +123,8 @@ func (oraimo *Oraimo) Index(page data.CrawledPage) {
 		productRatingText := ratingEl.Attrs()["title"]
 
 		rating, err = strconv.ParseFloat(productRatingText, 64)
-		if utils.HandleErr(err, "Failed to convert Oraimo product price") {
-			return
+		if err != nil {
+			return data.Product{}, fmt.Errorf("failed to parse Oraimo product rating: %w", err)
 		}
 	}
 
@@ -123,6 +141,7 @@ func (oraimo *Oraimo) Index(page data.CrawledPage) {
 	productData := data.Product{
 		Name:        productName,
 		Price:       price,
+		PriceInfo:   priceInfo,
 		Rating:      rating,
 		Description: productDescription,
 		URL:         page.URL,
@@ -130,10 +149,7 @@ func (oraimo *Oraimo) Index(page data.CrawledPage) {
 		Images:      productImages,
 	}
 
-	err = oraimo.db.IndexProduct(productData)
-	if utils.HandleErr(err, "Failed to index Oraimo product") {
-		return
-	}
+	return productData, nil
 }
 
 func (oraimo *Oraimo) Sniff(wg *sync.WaitGroup) {
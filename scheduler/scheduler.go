@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/config"
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Bounds returns a target's recrawl interval bounds, falling back to the
+// global ones for anything it doesn't set or sets outside of them.
+func Bounds(target data.Target) (time.Duration, time.Duration) {
+	minInterval := config.RECRAWL_MIN_INTERVAL
+	maxInterval := config.RECRAWL_MAX_INTERVAL
+
+	if interval, err := time.ParseDuration(target.Recrawl.MinInterval); err == nil && interval > minInterval {
+		minInterval = interval
+	}
+
+	if interval, err := time.ParseDuration(target.Recrawl.MaxInterval); err == nil && interval < maxInterval && interval >= minInterval {
+		maxInterval = interval
+	}
+
+	return minInterval, maxInterval
+}
+
+// Schedule continuously re-queues a target's products that are due for a recrawl.
+//
+// How often a product is due adapts to how often its price or availability changes
+// (see database.NextCrawlInterval), within the target's bounds.
+func Schedule(target data.Target, db *database.Database) {
+	minInterval, maxInterval := Bounds(target)
+
+	utils.Log(utils.Scheduler).Info("Scheduling recrawls", "source", target.Target, "min_interval", minInterval, "max_interval", maxInterval)
+
+	for {
+		queued, err := scheduleDue(target, db, minInterval, maxInterval)
+		if !utils.HandleErr(err, utils.Scheduler, "Failed to schedule recrawls", "source", target.Target) && queued > 0 {
+			utils.Log(utils.Scheduler).Info("Queued products for recrawl", "source", target.Target, "queued", queued)
+		}
+
+		time.Sleep(config.RECRAWL_TICK)
+	}
+}
+
+// scheduleDue queues one batch of due products, unless the target's queue is already busy.
+func scheduleDue(target data.Target, db *database.Database, minInterval, maxInterval time.Duration) (int, error) {
+	queueLength, err := db.CountQueue(target.Target)
+	if err != nil {
+		return 0, err
+	}
+
+	if queueLength >= config.RECRAWL_MAX_QUEUED {
+		utils.Log(utils.Scheduler).Info("Queue is busy, skipping recrawls this round", "source", target.Target)
+		return 0, nil
+	}
+
+	batch := min(config.RECRAWL_BATCH, config.RECRAWL_MAX_QUEUED-int(queueLength))
+
+	urls, err := db.GetDueProducts(target.Target, minInterval, maxInterval, batch)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+
+	for _, url := range urls {
+		err = db.Enqueue(context.Background(), data.UrlQueue{
+			URL:     url,
+			Source:  target.Target,
+			Recrawl: true,
+		})
+
+		// Already queued, e.g. by the sniffer.
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+
+		if utils.HandleErr(err, utils.Scheduler, "Failed to queue for recrawl", "source", target.Target, "url", url) {
+			continue
+		}
+
+		queued++
+	}
+
+	return queued, nil
+}
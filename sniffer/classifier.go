@@ -0,0 +1,196 @@
+package sniffer
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/anaskhan96/soup"
+)
+
+type LinkClass = string
+
+const (
+	Product    LinkClass = "product"
+	Listing    LinkClass = "listing"
+	Irrelevant LinkClass = "irrelevant"
+)
+
+// irrelevantKeywords are path segments that never lead to products
+// on any of the shops we crawl.
+var irrelevantKeywords = []string{
+	"login", "logout", "signin", "sign-in", "signup", "register",
+	"account", "customer", "cart", "checkout", "wishlist",
+	"help", "faq", "contact", "about", "privacy", "terms",
+	"policy", "careers", "sell", "blog", "track", "returns",
+}
+
+var (
+	priceRe   = regexp.MustCompile(`(?i)(₵|gh¢|ghs|gh₵)\s*[\d,]+`)
+	productRe = regexp.MustCompile(`(-\d{5,}\.html$)|([A-Za-z0-9]{16,}\.html$)|(/p=\d+$)|(/product/)`)
+	listingRe = regexp.MustCompile(`(/c=\d+$)|(/collections?/)|(/category/)|([?&]page=\d+)`)
+)
+
+type rule struct {
+	class LinkClass
+	re    *regexp.Regexp
+}
+
+// Classifier decides whether a link found while sniffing points to
+// a product, a listing or something we don't care about.
+type Classifier struct {
+	rules []rule
+}
+
+// NewClassifier builds a Classifier from the link rules of a target.
+//
+// Ignore rules are checked first, then product rules, then listing rules.
+// Rules that fail to compile are skipped and reported in the returned error slice.
+func NewClassifier(target data.Target) (*Classifier, []error) {
+	classifier := &Classifier{}
+
+	errs := []error{}
+
+	groups := []struct {
+		class    LinkClass
+		patterns []string
+	}{
+		{Irrelevant, target.Rules.Ignore},
+		{Product, target.Rules.Product},
+		{Listing, target.Rules.Listing},
+	}
+
+	for _, group := range groups {
+		for _, pattern := range group.patterns {
+			re, err := CompilePattern(pattern)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			classifier.rules = append(classifier.rules, rule{class: group.class, re: re})
+		}
+	}
+
+	return classifier, errs
+}
+
+// CompilePattern compiles a link rule into a regular expression.
+//
+// Patterns prefixed with "re:" are used as regular expressions as is.
+// Everything else is treated as a glob matched against the path and query,
+// where `**` matches anything, `*` matches anything but `/` and `?` matches one character.
+func CompilePattern(pattern string) (*regexp.Regexp, error) {
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		return regexp.Compile(expr)
+	}
+
+	var expr strings.Builder
+
+	expr.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				expr.WriteString(".*")
+				i++
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	expr.WriteString("$")
+
+	return regexp.Compile(expr.String())
+}
+
+// Classify returns the class of the link `u` found in the anchor `el`.
+//
+// Target rules take precedence; when none match, the link is classified
+// using features of the URL and the anchor element.
+func (classifier *Classifier) Classify(u *url.URL, el soup.Root) LinkClass {
+	ref := u.EscapedPath()
+	if u.RawQuery != "" {
+		ref += "?" + u.RawQuery
+	}
+
+	for _, rule := range classifier.rules {
+		if rule.re.MatchString(ref) {
+			return rule.class
+		}
+	}
+
+	return classifyByFeatures(u, el)
+}
+
+// classifyByFeatures is a scoring heuristic used when no target rule matches.
+//
+// Product cards usually wrap an image and a price and point to a deep,
+// id-bearing path. Listings tend to be short paths or paginated pages.
+func classifyByFeatures(u *url.URL, el soup.Root) LinkClass {
+	path := strings.ToLower(strings.Trim(u.Path, "/"))
+
+	if path == "" || strings.HasPrefix(u.Scheme, "javascript") || strings.HasPrefix(u.Scheme, "mailto") || strings.HasPrefix(u.Scheme, "tel") {
+		return Irrelevant
+	}
+
+	segments := strings.Split(path, "/")
+
+	for _, segment := range segments {
+		for _, keyword := range irrelevantKeywords {
+			if segment == keyword || strings.HasPrefix(segment, keyword+".") || strings.HasPrefix(segment, keyword+"-") {
+				return Irrelevant
+			}
+		}
+	}
+
+	productScore, listingScore := 0, 0
+
+	if productRe.MatchString(u.Path) {
+		productScore += 2
+	}
+
+	if listingRe.MatchString(u.String()) {
+		listingScore += 2
+	}
+
+	lastSegment := segments[len(segments)-1]
+
+	if strings.Count(lastSegment, "-") >= 3 {
+		productScore++
+	}
+
+	if len(segments) <= 2 && strings.Count(lastSegment, "-") < 3 {
+		listingScore++
+	}
+
+	if u.Query().Get("ean") != "" {
+		productScore += 2
+	}
+
+	if el.Error == nil && el.Pointer != nil {
+		if len(el.FindAll("img")) > 0 {
+			productScore++
+		}
+
+		if priceRe.MatchString(el.FullText()) {
+			productScore += 2
+		}
+	}
+
+	switch {
+	case productScore == 0 && listingScore == 0:
+		return Irrelevant
+	case productScore > listingScore:
+		return Product
+	default:
+		return Listing
+	}
+}
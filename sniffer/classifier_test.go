@@ -0,0 +1,146 @@
+package sniffer
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/anaskhan96/soup"
+)
+
+func TestCompilePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		ref     string
+		want    bool
+	}{
+		{"/product/*", "/product/tecno-spark-10", true},
+		{"/product/*", "/product/phones/tecno-spark-10", false},
+		{"/product/**", "/product/phones/tecno-spark-10", true},
+		{"/p=???", "/p=123", true},
+		{"/p=???", "/p=1234", false},
+		{"/phones", "/phones/tecno-spark-10", false},
+		{"/*.html", "/tecno-spark-10.html", true},
+		{"/*.html", "/tecno-spark-10xhtml", false},
+		{"**?page=*", "/phones/?page=2", true},
+		{"re:^/p=\\d+$", "/p=42", true},
+		{"re:^/p=\\d+$", "/p=42/reviews", false},
+		{"re:tecno", "/phones/tecno-spark-10", true},
+	}
+
+	for _, test := range tests {
+		re, err := CompilePattern(test.pattern)
+		if err != nil {
+			t.Errorf("CompilePattern(%q): %v", test.pattern, err)
+			continue
+		}
+
+		if got := re.MatchString(test.ref); got != test.want {
+			t.Errorf("%q matches %q = %v, want %v", test.pattern, test.ref, got, test.want)
+		}
+	}
+}
+
+func TestNewClassifierSkipsInvalidRules(t *testing.T) {
+	classifier, errs := NewClassifier(data.Target{Rules: data.LinkRules{
+		Product: []string{"re:(", "/*.html"},
+	}})
+
+	if len(errs) != 1 {
+		t.Fatalf("got errors %v, want one for the invalid rule", errs)
+	}
+
+	u, _ := url.Parse("https://www.jumia.com.gh/tecno-spark-10.html")
+	if got := classifier.Classify(u, soup.Root{}); got != Product {
+		t.Errorf("Classify = %s, want %s from the valid rule", got, Product)
+	}
+}
+
+func TestClassifyRulePrecedence(t *testing.T) {
+	classifier, errs := NewClassifier(data.Target{Rules: data.LinkRules{
+		Ignore:  []string{"/account/**", "re:[?&]sort="},
+		Product: []string{"re:\\.html(\\?|$)"},
+		Listing: []string{"/*/", "**?page=*"},
+	}})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	tests := []struct {
+		name string
+		href string
+		want LinkClass
+	}{
+		{"product rule", "/tecno-spark-10.html", Product},
+		{"listing glob", "/phones/", Listing},
+		{"glob matched against the query", "/phones/tecno?page=2", Listing},
+		{"ignore wins over product", "/account/orders.html", Irrelevant},
+		{"ignore wins over listing", "/phones/tecno?page=2&sort=price", Irrelevant},
+		{"product wins over listing", "/phones/tecno.html?page=2", Product},
+		// No rule matches, so the heuristics decide.
+		{"no rule", "/tecno-spark-10-pro-48823011", Product},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := url.Parse("https://www.jumia.com.gh" + test.href)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := classifier.Classify(u, soup.Root{}); got != test.want {
+				t.Errorf("Classify(%s) = %s, want %s", test.href, got, test.want)
+			}
+		})
+	}
+}
+
+func TestClassifyByFeatures(t *testing.T) {
+	const (
+		card      = `<a href="#"><img src="tecno.jpg"><span>GH₵ 1,299.00</span></a>`
+		imageOnly = `<a href="#"><img src="tecno.jpg"></a>`
+		textOnly  = `<a href="#">Phones</a>`
+	)
+
+	tests := []struct {
+		name   string
+		href   string
+		anchor string
+		want   LinkClass
+	}{
+		{"home page", "https://www.jumia.com.gh/", "", Irrelevant},
+		{"mail link", "mailto:support@jumia.com.gh", "", Irrelevant},
+		{"phone link", "tel:+233302000000", "", Irrelevant},
+		{"account keyword", "https://www.jumia.com.gh/customer/account/login/", "", Irrelevant},
+		{"keyword prefix", "https://www.jumia.com.gh/help-center", "", Irrelevant},
+		{"keyword prefix wins over product features", "https://www.jumia.com.gh/cart-trolley-heavy-duty-48823011.html", "", Irrelevant},
+		{"id-bearing slug", "https://www.jumia.com.gh/tecno-spark-10-pro-48823011.html", "", Product},
+		{"product path", "https://www.oraimo.com/gh/product/freepods-4", "", Product},
+		{"short path", "https://www.jumia.com.gh/phones-tablets/", textOnly, Listing},
+		{"paginated", "https://www.jumia.com.gh/phones/?page=2", "", Listing},
+		{"category path", "https://www.deus.com.gh/category/deals/", "", Listing},
+		{"ean query", "https://www.ishtari.com.gh/item?ean=6923450656112", "", Product},
+		{"nothing to go on", "https://www.jumia.com.gh/a/b/c/item", "", Irrelevant},
+		{"product card", "https://www.jumia.com.gh/a/b/c/item", card, Product},
+		{"image alone ties with a short path", "https://www.jumia.com.gh/phones/tecno", imageOnly, Listing},
+		{"image and price beat a short path", "https://www.jumia.com.gh/phones/tecno", card, Product},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := url.Parse(test.href)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			el := soup.Root{}
+			if test.anchor != "" {
+				el = soup.HTMLParse(test.anchor).Find("a")
+			}
+
+			if got := classifyByFeatures(u, el); got != test.want {
+				t.Errorf("classifyByFeatures(%s) = %s, want %s", test.href, got, test.want)
+			}
+		})
+	}
+}
@@ -1,42 +1,231 @@
 package sniffer
 
 import (
-	"log"
+	"context"
+	"fmt"
 	"net/url"
 	"time"
 
+	"github.com/Cedi-Search/Cedi-Search-Engine/archive"
+	"github.com/Cedi-Search/Cedi-Search-Engine/config"
 	"github.com/Cedi-Search/Cedi-Search-Engine/data"
 	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+	"github.com/Cedi-Search/Cedi-Search-Engine/metrics"
+	"github.com/Cedi-Search/Cedi-Search-Engine/tracing"
 	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
 	"github.com/anaskhan96/soup"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// frontierItem is a listing page waiting to be sniffed.
+type frontierItem struct {
+	path  string
+	depth int
+}
+
+// Report summarises a single sniff cycle of a target.
+type Report struct {
+	Source       string
+	Fetched      int
+	Resumed      int
+	Counts       map[LinkClass]int
+	Queued       int
+	DepthLimited int
+	PageLimitHit bool
+	FrontierLeft int
+	Duration     time.Duration
+	// Blocked is why the cycle stopped early because the source is blocking us.
+	Blocked string
+}
+
+func (report Report) String() string {
+	return fmt.Sprintf(
+		"cycle done in %s: fetched %d pages, resumed %d from cache, %d product / %d listing / %d irrelevant links, queued %d, %d beyond max depth, page cap hit: %v, %d left in frontier",
+		report.Duration.Round(time.Second),
+		report.Fetched,
+		report.Resumed,
+		report.Counts[Product],
+		report.Counts[Listing],
+		report.Counts[Irrelevant],
+		report.Queued,
+		report.DepthLimited,
+		report.PageLimitHit,
+		report.FrontierLeft,
+	) + blockedSuffix(report.Blocked)
+}
+
+func blockedSuffix(reason string) string {
+	if reason == "" {
+		return ""
+	}
+
+	return ", stopped early: blocked (" + reason + ")"
+}
+
+// Sniff continuously discovers product URLs for a target.
+//
+// Each cycle walks the target's listing pages breadth first starting at its seed path,
+// bounded by config.SNIFF_MAX_DEPTH and config.SNIFF_MAX_PAGES. Pages sniffed within
+// config.SNIFF_REVISIT_AFTER are not fetched again; their stored links are used instead,
+// so a restarted sniffer resumes where it stopped.
 func Sniff(target data.Target, db *database.Database) {
-	utils.Logger(utils.Sniffer, target.Target, "Sniffing...")
+	for {
+		report := SniffCycle(target, db)
+
+		utils.Log(utils.Sniffer).Info(report.String(), "source", target.Target)
+
+		utils.Log(utils.Sniffer).Info("Waiting to start next sniff cycle", "source", target.Target, "delay", config.SNIFF_CYCLE_DELAY)
+		time.Sleep(config.SNIFF_CYCLE_DELAY)
+	}
+}
+
+// SniffCycle runs a single breadth-first sniff of a target and reports what it found.
+func SniffCycle(target data.Target, db *database.Database) Report {
+	utils.Log(utils.Sniffer).Info("Sniffing", "source", target.Target)
+
+	start := time.Now()
+
+	report := Report{
+		Source: target.Target,
+		Counts: map[LinkClass]int{},
+	}
+
+	classifier, errs := NewClassifier(target)
+	for _, err := range errs {
+		utils.HandleErr(err, utils.Sniffer, "Invalid link rule", "source", target.Target)
+	}
+
+	seedPath := target.SeedPath
+	if seedPath == "" {
+		seedPath = "/"
+	}
+
+	frontier := []frontierItem{{path: seedPath, depth: 0}}
+	seen := map[string]bool{seedPath: true}
+
+	for len(frontier) > 0 {
+		if report.Fetched >= config.SNIFF_MAX_PAGES {
+			report.PageLimitHit = true
+			break
+		}
+
+		state, err := db.GetBlockState(target.Target)
+		if !utils.HandleErr(err, utils.Sniffer, "Failed to get block state", "source", target.Target) && time.Now().Before(state.BlockedUntil) {
+			report.Blocked = state.Reason
+			break
+		}
+
+		item := frontier[0]
+		frontier = frontier[1:]
+
+		listings, fetched := sniffPage(target, item, classifier, db, &report)
+
+		if fetched {
+			report.Fetched++
+		} else {
+			report.Resumed++
+		}
+
+		for _, listing := range listings {
+			if seen[listing] {
+				continue
+			}
+
+			seen[listing] = true
+
+			if item.depth+1 > config.SNIFF_MAX_DEPTH {
+				report.DepthLimited++
+				continue
+			}
+
+			frontier = append(frontier, frontierItem{path: listing, depth: item.depth + 1})
+		}
+
+		if fetched && len(frontier) > 0 {
+			time.Sleep(config.SNIFF_PAGE_DELAY)
+		}
+	}
+
+	report.FrontierLeft = len(frontier)
+	report.Duration = time.Since(start)
+
+	return report
+}
+
+// sniffPage sniffs a single listing page of a target.
+//
+// Product links are added to the crawl queue and listing links are returned
+// so they can be added to the frontier. The boolean is false when the page
+// was resumed from the visited set instead of being fetched.
+func sniffPage(target data.Target, item frontierItem, classifier *Classifier, db *database.Database, report *Report) ([]string, bool) {
+	visited, err := db.GetSniffedPage(target.Target, item.path)
+	if err == nil && time.Since(visited.SniffedAt) < config.SNIFF_REVISIT_AFTER {
+		utils.Log(utils.Sniffer).Debug("Resuming from visited set", "source", target.Target, "path", item.path)
+		return visited.Listings, false
+	}
 
 	link := url.URL{}
 
 	link.Host = target.Host
-	link.Path = target.SeedPath
 	link.Scheme = "https"
 
-	resp := utils.FetchPage(link.String(), "rod")
+	seed, err := url.Parse(item.path)
+	if err != nil {
+		utils.HandleErr(err, utils.Sniffer, "Invalid sniff path", "path", item.path)
+		return []string{}, true
+	}
+
+	link.Path = seed.Path
+	link.RawQuery = seed.RawQuery
 
-	doc := soup.HTMLParse(resp)
+	ctx, span := tracing.Start(context.Background(), "sniff.listing", trace.WithAttributes(
+		attribute.String("source", target.Target),
+		attribute.String("url", link.String()),
+		attribute.Int("depth", item.depth),
+	))
+	defer span.End()
+
+	resp, err := utils.Fetch(link.String(), "rod")
+	if utils.HandleErr(err, utils.Sniffer, "Failed to fetch", "url", link.String()) {
+		span.RecordError(err)
+		return []string{}, true
+	}
+
+	span.SetAttributes(attribute.Int("http.status_code", resp.Status))
+
+	if reason := utils.DetectBlock(resp); reason != "" {
+		state, err := db.MarkBlocked(target.Target, target.Host, link.String(), reason)
+		utils.HandleErr(err, utils.Sniffer, "Failed to mark as blocked", "source", target.Target)
+
+		utils.Log(utils.Sniffer).Warn("Blocked", "source", target.Target, "url", link.String(), "reason", reason, "until", state.BlockedUntil)
+
+		report.Blocked = reason
+
+		span.SetAttributes(attribute.String("blocked", reason))
+
+		return []string{}, true
+	}
+
+	_, err = archive.Default().Store(target.Target, resp)
+	utils.HandleErr(err, utils.Sniffer, "Failed to archive", "url", link.String())
+
+	doc := soup.HTMLParse(resp.Body)
 
 	links := doc.FindAll("a")
 
 	utils.ShuffleLinks(links)
 
-	newlyFound := []data.Target{}
+	listings := []string{}
+	listingExists := map[string]bool{}
 
 	for _, link := range links {
 		categoryLink := link.Attrs()["href"]
 
 		u, err := url.Parse(categoryLink)
 		if err != nil {
-			log.Println(err)
-			return
+			utils.Log(utils.Sniffer).Debug("Invalid link", "source", target.Target, "href", categoryLink, "error", err)
+			continue
 		}
 
 		if u.Host != target.Host && u.Host != "" {
@@ -48,42 +237,48 @@ func Sniff(target data.Target, db *database.Database) {
 			u.Scheme = "https"
 		}
 
-		if canQueue, err := db.CanQueueUrl(u.String()); err == nil && canQueue {
+		u.Fragment = ""
 
-			newTarget := data.Target{
-				Target:   target.Target,
-				Host:     target.Host,
-				SeedPath: u.Path,
-			}
+		class := classifier.Classify(u, link)
 
-			db.AddToQueue(data.UrlQueue{
-				URL:    u.String(),
-				Source: target.Target,
-			})
+		report.Counts[class]++
 
-			targetExists := false
+		metrics.SniffedLinks.WithLabelValues(target.Target, class).Inc()
 
-			for _, data := range newlyFound {
-				if data.SeedPath == newTarget.SeedPath {
-					targetExists = true
-					break
+		switch class {
+		case Product:
+			if canQueue, err := db.CanQueueUrl(u.String()); err == nil && canQueue {
+				err = db.Enqueue(ctx, data.UrlQueue{
+					URL:    u.String(),
+					Source: target.Target,
+				})
+				if !utils.HandleErr(err, utils.Sniffer, "Failed to queue", "url", u.String()) {
+					report.Queued++
+					metrics.SniffedProducts.WithLabelValues(target.Target, "queued").Inc()
 				}
+			} else {
+				metrics.SniffedProducts.WithLabelValues(target.Target, "skipped").Inc()
 			}
 
-			if !targetExists {
-				newlyFound = append(newlyFound, newTarget)
+		case Listing:
+			if !listingExists[u.RequestURI()] {
+				listingExists[u.RequestURI()] = true
+				listings = append(listings, u.RequestURI())
 			}
-
 		}
 
 	}
 
-	utils.Logger(utils.Sniffer, target.Target, "Wait 120s to continue sniff")
-	time.Sleep(120 * time.Second)
+	err = db.MarkSniffed(data.SniffedPage{
+		Source:    target.Target,
+		Path:      item.path,
+		Depth:     item.depth,
+		Listings:  listings,
+		SniffedAt: time.Now(),
+	})
+	utils.HandleErr(err, utils.Sniffer, "Failed to mark as sniffed", "path", item.path)
 
-	for _, newTarget := range newlyFound {
+	span.SetAttributes(attribute.Int("listings", len(listings)))
 
-		go Sniff(newTarget, db)
-
-	}
+	return listings, true
 }
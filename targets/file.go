@@ -0,0 +1,291 @@
+package targets
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"gopkg.in/yaml.v3"
+)
+
+// FileError is a problem with a target file, located as precisely as we can.
+type FileError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (err FileError) Error() string {
+	if err.Path == "" {
+		return fmt.Sprintf("%s:%d:%d: %s", err.File, err.Line, err.Column, err.Message)
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s: %s", err.File, err.Line, err.Column, err.Path, err.Message)
+}
+
+// Extensions are the file extensions of target files. JSON is read as YAML,
+// which it is a subset of, so both get line and column numbers.
+var Extensions = []string{".yaml", ".yml", ".json"}
+
+var yamlLineRe = regexp.MustCompile(`line (\d+)`)
+
+// LoadFile reads a target from a YAML or JSON file and checks it against the
+// target schema: unknown fields, wrong types and everything Validate checks.
+func LoadFile(file string) (data.Target, []FileError) {
+	target := data.Target{}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return target, []FileError{{File: file, Message: err.Error()}}
+	}
+
+	doc := yaml.Node{}
+
+	err = yaml.Unmarshal(content, &doc)
+	if err != nil {
+		line := 0
+		if match := yamlLineRe.FindStringSubmatch(err.Error()); match != nil {
+			line, _ = strconv.Atoi(match[1])
+		}
+
+		return target, []FileError{{File: file, Line: line, Message: err.Error()}}
+	}
+
+	if len(doc.Content) == 0 {
+		return target, []FileError{{File: file, Line: 1, Column: 1, Message: "file is empty"}}
+	}
+
+	root := doc.Content[0]
+
+	checker := schemaChecker{file: file, nodes: map[string]*yaml.Node{"": root}}
+
+	checker.check(root, reflect.TypeOf(target), "")
+
+	if len(checker.errs) > 0 {
+		return target, checker.errs
+	}
+
+	// The schema matched, so the generic value maps onto the json tags of data.Target.
+	var value interface{}
+
+	err = root.Decode(&value)
+	if err == nil {
+		var encoded []byte
+
+		encoded, err = json.Marshal(value)
+		if err == nil {
+			err = json.Unmarshal(encoded, &target)
+		}
+	}
+
+	if err != nil {
+		return target, []FileError{{File: file, Line: root.Line, Column: root.Column, Message: err.Error()}}
+	}
+
+	for _, fieldErr := range Validate(target) {
+		node := checker.locate(fieldErr.Path)
+
+		checker.errs = append(checker.errs, FileError{
+			File:    file,
+			Line:    node.Line,
+			Column:  node.Column,
+			Path:    fieldErr.Path,
+			Message: fieldErr.Message,
+		})
+	}
+
+	return target, checker.errs
+}
+
+// LoadDir reads every target file in a directory, one shop per file.
+func LoadDir(dir string) ([]data.Target, []FileError) {
+	targets := []data.Target{}
+	errs := []FileError{}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return targets, []FileError{{File: dir, Message: err.Error()}}
+	}
+
+	seen := map[string]string{}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !contains(Extensions, strings.ToLower(filepath.Ext(entry.Name()))) {
+			continue
+		}
+
+		file := filepath.Join(dir, entry.Name())
+
+		target, fileErrs := LoadFile(file)
+		if len(fileErrs) > 0 {
+			errs = append(errs, fileErrs...)
+			continue
+		}
+
+		if other, found := seen[strings.ToLower(target.Target)]; found {
+			errs = append(errs, FileError{File: file, Line: 1, Column: 1, Path: "target", Message: fmt.Sprintf("%s is already defined in %s", target.Target, other)})
+			continue
+		}
+
+		seen[strings.ToLower(target.Target)] = file
+
+		targets = append(targets, target)
+	}
+
+	return targets, errs
+}
+
+// schemaChecker walks a YAML document alongside the Go type it should decode
+// into, reporting where the two disagree and remembering the node of every path.
+type schemaChecker struct {
+	file  string
+	errs  []FileError
+	nodes map[string]*yaml.Node
+}
+
+func (checker *schemaChecker) fail(node *yaml.Node, path, format string, args ...interface{}) {
+	checker.errs = append(checker.errs, FileError{
+		File:    checker.file,
+		Line:    node.Line,
+		Column:  node.Column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (checker *schemaChecker) check(node *yaml.Node, t reflect.Type, path string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	checker.nodes[path] = node
+
+	// A null leaves the field at its zero value.
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			checker.fail(node, path, "must be an object")
+			return
+		}
+
+		fields := jsonFields(t)
+		seen := map[string]bool{}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldPath := join(path, key.Value)
+
+			if seen[key.Value] {
+				checker.fail(key, fieldPath, "is defined twice")
+				continue
+			}
+
+			seen[key.Value] = true
+
+			field, found := fields[key.Value]
+			if !found {
+				checker.fail(key, fieldPath, "unknown field, expected one of %s", strings.Join(sortedKeys(fields), ", "))
+				continue
+			}
+
+			checker.check(value, field, fieldPath)
+		}
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			checker.fail(node, path, "must be a list")
+			return
+		}
+
+		for i, item := range node.Content {
+			checker.check(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+
+	case reflect.String:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!str" {
+			checker.fail(node, path, "must be a string")
+		}
+
+	case reflect.Bool:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" {
+			checker.fail(node, path, "must be true or false")
+		}
+
+	case reflect.Int, reflect.Int64:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!int" {
+			checker.fail(node, path, "must be a whole number")
+		}
+	}
+}
+
+// locate finds the node of a path, or of its nearest ancestor when the
+// path isn't in the file, e.g. a required field that's missing.
+func (checker *schemaChecker) locate(path string) *yaml.Node {
+	for {
+		if node, found := checker.nodes[path]; found {
+			return node
+		}
+
+		cut := strings.LastIndexAny(path, ".[")
+		if cut < 0 {
+			return checker.nodes[""]
+		}
+
+		path = path[:cut]
+	}
+}
+
+// jsonFields maps the json names of a struct's fields to their types.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fields[name] = field.Type
+	}
+
+	return fields
+}
+
+func sortedKeys(fields map[string]reflect.Type) []string {
+	keys := []string{}
+
+	for key := range fields {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func join(path, field string) string {
+	if path == "" {
+		return field
+	}
+
+	return path + "." + field
+}
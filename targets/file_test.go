@@ -0,0 +1,115 @@
+package targets
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// valid is a minimal target file without problems. The cases below break it
+// one way each, and expect the error where the break is.
+const valid = `target: Jumia
+host: www.jumia.com.gh
+data:
+  - label: name
+    datatype: string
+    selector:
+      element: h1
+`
+
+func TestLoadFileLocatesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		line    int
+		column  int
+		path    string
+		message string
+	}{
+		{
+			name:    "unknown field",
+			content: strings.Replace(valid, "    datatype: string\n", "    datatype: string\n    requird: true\n", 1),
+			line:    6,
+			column:  5,
+			path:    "data[0].requird",
+			message: "unknown field",
+		},
+		{
+			name:    "wrong type",
+			content: strings.Replace(valid, "      element: h1", "      element: [h1, h2]", 1),
+			line:    7,
+			column:  16,
+			path:    "data[0].selector.element",
+			message: "must be a string",
+		},
+		{
+			name:    "duplicate key",
+			content: valid + "host: www.jumia.com.ng\n",
+			line:    8,
+			column:  1,
+			path:    "host",
+			message: "is defined twice",
+		},
+		{
+			name:    "missing required field",
+			content: strings.Replace(valid, "      element: h1\n", "      attribute: class\n      value: -prxs\n", 1),
+			line:    7,
+			column:  7,
+			path:    "data[0].selector.element",
+			message: "is required",
+		},
+		{
+			name:    "missing top-level field",
+			content: strings.Replace(valid, "target: Jumia\n", "", 1),
+			line:    1,
+			column:  1,
+			path:    "target",
+			message: "is required",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "jumia.yaml")
+
+			err := os.WriteFile(file, []byte(test.content), 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, errs := LoadFile(file)
+			if len(errs) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(errs), errs)
+			}
+
+			got := errs[0]
+
+			if got.File != file || got.Line != test.line || got.Column != test.column || got.Path != test.path {
+				t.Errorf("got %s:%d:%d %s, want %s:%d:%d %s", got.File, got.Line, got.Column, got.Path, file, test.line, test.column, test.path)
+			}
+
+			if !strings.HasPrefix(got.Message, test.message) {
+				t.Errorf("got message %q, want it to start with %q", got.Message, test.message)
+			}
+		})
+	}
+}
+
+func TestLoadFileAcceptsValidTarget(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "jumia.yaml")
+
+	err := os.WriteFile(file, []byte(valid), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target, errs := LoadFile(file)
+	if len(errs) > 0 {
+		t.Fatalf("got errors: %v", errs)
+	}
+
+	if target.Target != "Jumia" || len(target.Data) != 1 || target.Data[0].Selector.Element != "h1" {
+		t.Errorf("got %+v", target)
+	}
+}
@@ -0,0 +1,99 @@
+package targets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/database"
+	"github.com/Cedi-Search/Cedi-Search-Engine/utils"
+)
+
+// ErrInvalidTargets is returned when target files don't pass validation.
+var ErrInvalidTargets = errors.New("invalid target files")
+
+// How target files combine with the targets in the database.
+const (
+	// MergeMode uses the database targets, with file targets replacing
+	// those of the same name and adding new ones.
+	MergeMode = "merge"
+	// FilesMode uses the file targets only.
+	FilesMode = "files"
+)
+
+// Merge combines database and file targets according to mode and drops disabled targets.
+func Merge(dbTargets, fileTargets []data.Target, mode string) []data.Target {
+	merged := []data.Target{}
+
+	fromFiles := map[string]bool{}
+	for _, target := range fileTargets {
+		fromFiles[strings.ToLower(target.Target)] = true
+	}
+
+	if mode != FilesMode {
+		for _, target := range dbTargets {
+			if !fromFiles[strings.ToLower(target.Target)] {
+				merged = append(merged, target)
+			}
+		}
+	}
+
+	merged = append(merged, fileTargets...)
+
+	enabled := []data.Target{}
+
+	for _, target := range merged {
+		if !target.Disabled {
+			enabled = append(enabled, target)
+		}
+	}
+
+	return enabled
+}
+
+// Load returns the targets to crawl. Without TARGETS_DIR they come from the
+// database. With it, the target files in that directory are validated and
+// combined with the database set as TARGETS_MODE says ("merge", the default,
+// or "files"). Every problem in the files is logged and fails the load.
+func Load(db *database.Database) ([]data.Target, error) {
+	dir := os.Getenv("TARGETS_DIR")
+	if dir == "" {
+		return db.GetTargets()
+	}
+
+	mode := os.Getenv("TARGETS_MODE")
+	if mode == "" {
+		mode = MergeMode
+	}
+
+	if mode != MergeMode && mode != FilesMode {
+		return nil, fmt.Errorf("TARGETS_MODE must be %s or %s, not %q", MergeMode, FilesMode, mode)
+	}
+
+	fileTargets, errs := LoadDir(dir)
+
+	for _, err := range errs {
+		utils.Log(utils.Default).Error("Invalid target file", "file", err.File, "line", err.Line, "column", err.Column, "path", err.Path, "error", err.Message)
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w in %s: %d problems", ErrInvalidTargets, dir, len(errs))
+	}
+
+	dbTargets := []data.Target{}
+
+	if mode == MergeMode {
+		var err error
+
+		dbTargets, err = db.GetTargets()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	utils.Log(utils.Default).Info("Loaded target files", "dir", dir, "mode", mode, "files", len(fileTargets), "database", len(dbTargets))
+
+	return Merge(dbTargets, fileTargets, mode), nil
+}
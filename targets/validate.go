@@ -0,0 +1,143 @@
+package targets
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/crawler"
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/sniffer"
+)
+
+// FieldError is a problem with one field of a target definition.
+// Path locates the field, e.g. "data[2].selector.element".
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (err FieldError) Error() string {
+	return err.Path + ": " + err.Message
+}
+
+// dataTypes are the data types the indexer knows how to extract.
+var dataTypes = []string{"string", "number", "price"}
+
+// Validate checks a target definition and lists every problem with it.
+func Validate(target data.Target) []FieldError {
+	errs := []FieldError{}
+
+	fail := func(path, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if strings.TrimSpace(target.Target) == "" {
+		fail("target", "is required")
+	}
+
+	if target.Host == "" {
+		fail("host", "is required")
+	} else if strings.Contains(target.Host, "/") {
+		fail("host", "must be a bare host like www.jumia.com.gh, not %q", target.Host)
+	}
+
+	if target.SeedPath != "" && !strings.HasPrefix(target.SeedPath, "/") {
+		fail("seed_path", "must start with /")
+	}
+
+	if len(target.Data) == 0 {
+		fail("data", "needs at least one attribute")
+	}
+
+	labels := map[string]int{}
+
+	for i, attrib := range target.Data {
+		path := fmt.Sprintf("data[%d]", i)
+
+		if attrib.Label == "" {
+			fail(path+".label", "is required")
+		} else if first, found := labels[attrib.Label]; found {
+			fail(path+".label", "%q is already used by data[%d]", attrib.Label, first)
+		} else {
+			labels[attrib.Label] = i
+		}
+
+		if !contains(dataTypes, attrib.DataType) {
+			fail(path+".datatype", "must be one of %s, not %q", strings.Join(dataTypes, ", "), attrib.DataType)
+		}
+
+		if attrib.Selector.Element == "" {
+			fail(path+".selector.element", "is required")
+		}
+
+		if (attrib.Selector.Attribute == "") != (attrib.Selector.Value == "") {
+			fail(path+".selector", "attribute and value go together")
+		}
+	}
+
+	rules := []struct {
+		name     string
+		patterns []string
+	}{
+		{"product", target.Rules.Product},
+		{"listing", target.Rules.Listing},
+		{"ignore", target.Rules.Ignore},
+	}
+
+	for _, group := range rules {
+		for i, pattern := range group.patterns {
+			if _, err := sniffer.CompilePattern(pattern); err != nil {
+				fail(fmt.Sprintf("rules.%s[%d]", group.name, i), "%v", err)
+			}
+		}
+	}
+
+	intervals := []struct {
+		path     string
+		interval string
+	}{
+		{"recrawl.min_interval", target.Recrawl.MinInterval},
+		{"recrawl.max_interval", target.Recrawl.MaxInterval},
+	}
+
+	for _, field := range intervals {
+		if field.interval == "" {
+			continue
+		}
+
+		if _, err := time.ParseDuration(field.interval); err != nil {
+			fail(field.path, "%v", err)
+		}
+	}
+
+	for i, proxy := range target.Proxies {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			fail(fmt.Sprintf("proxies[%d]", i), "%v", err)
+			continue
+		}
+
+		if !contains([]string{"http", "https", "socks5", "socks5h"}, u.Scheme) || u.Host == "" {
+			fail(fmt.Sprintf("proxies[%d]", i), "must be an http(s):// or socks5:// URL")
+		}
+
+		// Chromium can't take proxy credentials, so they'd be dropped silently.
+		if u.User != nil && crawler.Fetcher(target.Target) == "rod" {
+			fail(fmt.Sprintf("proxies[%d]", i), "%s is fetched with the headless browser, which can't use proxy credentials; use a proxy that authorises by IP", target.Target)
+		}
+	}
+
+	return errs
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+)
+
+// CanonicalURL normalises a product URL so that the same product
+// always maps to the same key, whichever link it was found through.
+//
+// The scheme and host are lowercased, and the query, fragment and
+// trailing slash are dropped. e.g. https://WWW.Jumia.com.gh/foo-123.html?pos=2#top
+// becomes https://www.jumia.com.gh/foo-123.html
+func CanonicalURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.RawQuery = ""
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	if len(u.Path) > 1 {
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawPath = ""
+	}
+
+	return u.String(), nil
+}
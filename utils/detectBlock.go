@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/config"
+)
+
+// challengeMarkers are bits of the captcha and challenge pages bot protections
+// serve instead of the page. A bare "captcha" isn't one: login forms have those.
+var challengeMarkers = []string{
+	"/cdn-cgi/challenge-platform", "cf-chl-", "cf_chl_opt", "checking your browser before accessing",
+	"captcha-delivery.com", "px-captcha", "please verify you are a human",
+	"<title>access denied</title>", "request unsuccessful. incapsula",
+}
+
+// DetectBlock reports why a response looks like the shop turned us away
+// rather than served the page, or "" when it doesn't.
+//
+// It looks at the status code, known challenge markers and
+// bodies too small to be a real page.
+func DetectBlock(resp Response) string {
+	switch resp.Status {
+	case http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return fmt.Sprintf("status %d", resp.Status)
+	}
+
+	body := strings.ToLower(resp.Body)
+
+	for _, marker := range challengeMarkers {
+		if strings.Contains(body, marker) {
+			return fmt.Sprintf("challenge marker %q", marker)
+		}
+	}
+
+	if resp.Status == http.StatusOK && len(resp.Body) < config.BLOCK_MIN_BODY {
+		return fmt.Sprintf("%d byte body", len(resp.Body))
+	}
+
+	return ""
+}
+
+// IsBlocked reports whether a response looks like a block page.
+func IsBlocked(resp Response) bool {
+	return DetectBlock(resp) != ""
+}
@@ -1,53 +1,302 @@
 package utils
 
 import (
-	"log"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/Cedi-Search/Cedi-Search-Engine/config"
-	"github.com/anaskhan96/soup"
+	"github.com/Cedi-Search/Cedi-Search-Engine/metrics"
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/launcher"
 	"github.com/go-rod/rod/lib/proto"
 )
 
-var controlUrl = launcher.New().Headless(true).MustLaunch()
+var browser *rod.Browser
+var browserOnce sync.Once
 
-var browser = rod.New().ControlURL(controlUrl).MustConnect().WithPanic(func(i interface{}) {
-	log.Println("[!] Headerless browser probably lost context.")
-})
+// getBrowser launches the headless browser the first time it is needed,
+// so commands that never fetch with "rod" don't start Chromium.
+func getBrowser() *rod.Browser {
+	browserOnce.Do(func() {
+		controlUrl := launcher.New().Headless(true).MustLaunch()
 
-// FetchPage fetches the content of a web page given its URL.
+		metrics.Browsers.Inc()
+
+		browser = rod.New().ControlURL(controlUrl).MustConnect().WithPanic(func(i interface{}) {
+			Log(Utils).Error("Headless browser probably lost context")
+		})
+	})
+
+	return browser
+}
+
+var httpClient = &http.Client{Timeout: config.FETCH_TIMEOUT}
+
+// Response is a fetched page together with what we know about how it was fetched.
 //
-// href: The URL of the web page to fetch.
-func FetchPage(href, fetcher string) string {
-	Logger(Utils, Utils, "Fetching ", href, " using ", fetcher)
+// For the "rod" fetcher, Body is the rendered DOM rather than the raw response body.
+type Response struct {
+	URL       string
+	FinalURL  string
+	Status    int
+	Header    http.Header
+	Body      string
+	FetchedAt time.Time
+	Fetcher   string
+}
+
+// Fetch fetches a web page given its URL using either
+// the headless browser ("rod") or a plain HTTP client ("soup").
+//
+// When the response cache is on, fresh cached pages are served from disk and
+// successful fetches are stored; in offline mode a miss returns ErrNotCached.
+func Fetch(href, fetcher string) (Response, error) {
+	cache := GetResponseCache()
+
+	if cache == nil {
+		return fetch(href, fetcher)
+	}
+
+	if resp, found := cache.Get(fetcher, href); found {
+		Log(Utils).Debug("Serving from the response cache", "url", href, "fetcher", fetcher)
+		return resp, nil
+	}
+
+	if cache.Offline {
+		return Response{URL: href, Fetcher: fetcher}, ErrNotCached
+	}
+
+	resp, err := fetch(href, fetcher)
+	if err != nil {
+		return resp, err
+	}
+
+	// Server errors and block pages are transient, don't pin them in the cache
+	if resp.Status < http.StatusInternalServerError && !IsBlocked(resp) {
+		HandleErr(cache.Put(resp), Utils, "Failed to cache response")
+	}
+
+	return resp, nil
+}
+
+// fetch fetches a web page with the given fetcher, bypassing the response cache.
+// It goes through a proxy of the host's pool when there is one.
+func fetch(href, fetcher string) (Response, error) {
+	proxy, err := proxyFor(href)
+	if err != nil {
+		return Response{URL: href, Fetcher: fetcher}, err
+	}
+
+	start := time.Now()
 
-	var html string
+	resp, err := fetchVia(href, fetcher, proxy)
+
+	observeFetch(href, fetcher, resp, err, start)
+
+	if proxy != nil {
+		proxy.report(resp, err)
+	}
+
+	return resp, err
+}
+
+// observeFetch records a fetch's latency and status; failed fetches have status "error".
+func observeFetch(href, fetcher string, resp Response, err error, start time.Time) {
+	status := strconv.Itoa(resp.Status)
+	if err != nil {
+		status = "error"
+	}
+
+	host := ""
+	if u, err := url.Parse(href); err == nil {
+		host = u.Host
+	}
+
+	metrics.FetchDuration.WithLabelValues(host, fetcher, status).Observe(time.Since(start).Seconds())
+}
+
+// fetchVia fetches a web page with the given fetcher through a proxy, or directly when it's nil.
+func fetchVia(href, fetcher string, proxy *Proxy) (Response, error) {
+	Log(Utils).Debug("Fetching", "url", href, "fetcher", fetcher, "proxy", proxy)
+
+	resp := Response{
+		URL:       href,
+		Header:    http.Header{},
+		FetchedAt: time.Now(),
+		Fetcher:   fetcher,
+	}
 
 	if fetcher == "rod" {
 
-		page := browser.MustPage()
+		b := getBrowser()
+
+		if proxy != nil {
+			b = proxy.getBrowser()
+		}
+
+		page := b.MustPage()
 
+		metrics.BrowserPages.Inc()
+
+		defer metrics.BrowserPages.Dec()
 		defer page.Close()
 
 		page.SetUserAgent(&proto.NetworkSetUserAgentOverride{
 			UserAgent: config.USER_AGENT,
 		})
 
-		page.Navigate(href)
+		// Failed navigations and pages served by service workers never send the
+		// document's response, so everything after this point is timed out.
+		timed := page.Timeout(config.FETCH_TIMEOUT)
+		defer timed.CancelTimeout()
 
-		page.MustWaitLoad()
+		waitResponse := timed.EachEvent(func(e *proto.NetworkResponseReceived) bool {
+			if e.Type != proto.NetworkResourceTypeDocument {
+				return false
+			}
 
-		html = page.MustHTML()
-	} else {
-		var err error
+			resp.Status = e.Response.Status
+
+			for key, value := range e.Response.Headers {
+				resp.Header.Set(key, value.String())
+			}
 
-		html, err = soup.Get(href)
+			return true
+		})
+
+		err := timed.Navigate(href)
 		if err != nil {
-			log.Fatalln(err)
+			return resp, err
+		}
+
+		waitResponse()
+
+		if err := timed.GetContext().Err(); err != nil {
+			return resp, fmt.Errorf("waiting for the response to %s: %w", href, err)
 		}
 
+		err = timed.WaitLoad()
+		if err != nil {
+			return resp, err
+		}
+
+		resp.Body, err = timed.HTML()
+		if err != nil {
+			return resp, err
+		}
+
+		resp.FinalURL = href
+
+		if info, err := timed.Info(); err == nil {
+			resp.FinalURL = info.URL
+		}
+	} else {
+		client := httpClient
+
+		if proxy != nil {
+			client = proxy.client
+		}
+
+		return get(client, href, http.Header{})
+	}
+
+	return resp, nil
+}
+
+// FetchIfModified makes a conditional GET with the plain HTTP client, sending the
+// validators of a previous response. A 304 status means the page hasn't changed.
+//
+// Either validator may be empty, but with neither the request is unconditional.
+// In offline mode the cached page is returned as if it had changed.
+func FetchIfModified(href, etag, lastModified string) (Response, error) {
+	if cache := GetResponseCache(); cache != nil && cache.Offline {
+		return Fetch(href, "soup")
+	}
+
+	Log(Utils).Debug("Revalidating", "url", href)
+
+	header := http.Header{}
+
+	if etag != "" {
+		header.Set("If-None-Match", etag)
 	}
 
-	return html
+	if lastModified != "" {
+		header.Set("If-Modified-Since", lastModified)
+	}
+
+	proxy, err := proxyFor(href)
+	if err != nil {
+		return Response{URL: href, Fetcher: "soup"}, err
+	}
+
+	client := httpClient
+
+	if proxy != nil {
+		client = proxy.client
+	}
+
+	start := time.Now()
+
+	resp, err := get(client, href, header)
+
+	observeFetch(href, "soup", resp, err, start)
+
+	if proxy != nil {
+		proxy.report(resp, err)
+	}
+
+	return resp, err
+}
+
+// get fetches a page with a plain HTTP client.
+func get(client *http.Client, href string, header http.Header) (Response, error) {
+	resp := Response{
+		URL:       href,
+		Header:    http.Header{},
+		FetchedAt: time.Now(),
+		Fetcher:   "soup",
+	}
+
+	req, err := http.NewRequest(http.MethodGet, href, nil)
+	if err != nil {
+		return resp, err
+	}
+
+	req.Header = header
+	req.Header.Set("User-Agent", config.USER_AGENT)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return resp, err
+	}
+
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.Status = res.StatusCode
+	resp.Header = res.Header
+	resp.FinalURL = res.Request.URL.String()
+	resp.Body = string(body)
+
+	return resp, nil
+}
+
+// FetchPage fetches the content of a web page given its URL.
+//
+// href: The URL of the web page to fetch.
+// It returns ErrNotCached for pages missing from the cache in offline mode.
+func FetchPage(href, fetcher string) (string, error) {
+	resp, err := Fetch(href, fetcher)
+
+	return resp.Body, err
 }
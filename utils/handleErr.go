@@ -1,9 +1,13 @@
 package utils
 
-// Print error to screen and write to error log file
-func HandleErr(err error, logStmt string) bool {
+// HandleErr logs a non-nil error at error level by the component it happened
+// in, e.g. Crawler, and reports whether there was one. The message should be
+// constant so its lines can be grouped; args are slog attributes, e.g.
+//
+//	utils.HandleErr(err, utils.Crawler, "Failed to fetch", "url", url.URL)
+func HandleErr(err error, component LogType, logStmt string, args ...interface{}) bool {
 	if err != nil {
-		Logger(Error, Error, logStmt)
+		Log(component).Error(logStmt, append(args, "error", err)...)
 	}
 
 	return err != nil
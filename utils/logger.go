@@ -1,34 +1,248 @@
 package utils
 
 import (
-	dxlogger "github.com/daxsome/daxsome-commons/pkg/logger"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 type LogType = string
 
 const (
-	Database LogType = "database"
-	Crawler  LogType = "crawler"
-	Indexer  LogType = "indexer"
-	Sniffer  LogType = "sniffer"
-	Utils    LogType = "utils"
+	Database  LogType = "database"
+	Crawler   LogType = "crawler"
+	Indexer   LogType = "indexer"
+	Sniffer   LogType = "sniffer"
+	Utils     LogType = "utils"
+	Alerts    LogType = "alerts"
+	Matcher   LogType = "matcher"
+	Scheduler LogType = "scheduler"
+	Health    LogType = "health"
 
 	Error   LogType = "error"
 	Default LogType = "default"
 )
 
-var logger = dxlogger.NewLogger()
+// LogConfig configures where and how much every component logs.
+//
+// Records are written as JSON to stdout and to <Dir>/<component>.log, and
+// errors of every component also to <Dir>/error.log. Files are rotated once
+// they reach MaxSizeMB, keeping MaxBackups old files for MaxAgeDays.
+type LogConfig struct {
+	Dir        string
+	Level      slog.Level
+	Levels     map[LogType]slog.Level
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+}
 
-func Logger(logType LogType, scope string, stmts ...interface{}) {
-	defer logger.ResetHandlers()
+// LogConfigFromEnv reads the logging configuration from the environment.
+//
+// LOG_DIR defaults to "logs"; LOG_DIR=- turns file output off.
+// LOG_LEVEL sets the default level and LOG_LEVELS per-component ones,
+// e.g. "database=warn,crawler=debug". LOG_MAX_SIZE_MB, LOG_MAX_BACKUPS
+// and LOG_MAX_AGE_DAYS control rotation.
+func LogConfigFromEnv() (LogConfig, error) {
+	cfg := LogConfig{
+		Dir:        "logs",
+		Level:      slog.LevelInfo,
+		Levels:     map[LogType]slog.Level{},
+		MaxSizeMB:  50,
+		MaxBackups: 5,
+		MaxAgeDays: 30,
+	}
 
-	logFiles := []string{
-		"crawler", "indexer", "sniffer", "database", "utils",
+	if dir, found := os.LookupEnv("LOG_DIR"); found {
+		cfg.Dir = dir
 	}
 
+	if cfg.Dir == "-" {
+		cfg.Dir = ""
+	}
+
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		err := cfg.Level.UnmarshalText([]byte(level))
+		if err != nil {
+			return cfg, fmt.Errorf("LOG_LEVEL: %w", err)
+		}
+	}
+
+	for _, pair := range strings.Split(os.Getenv("LOG_LEVELS"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		component, level, found := strings.Cut(pair, "=")
+		if !found {
+			return cfg, fmt.Errorf("LOG_LEVELS: %q isn't component=level", pair)
+		}
+
+		var l slog.Level
+
+		err := l.UnmarshalText([]byte(strings.TrimSpace(level)))
+		if err != nil {
+			return cfg, fmt.Errorf("LOG_LEVELS: %w", err)
+		}
+
+		cfg.Levels[strings.TrimSpace(component)] = l
+	}
+
+	for env, value := range map[string]*int{
+		"LOG_MAX_SIZE_MB":  &cfg.MaxSizeMB,
+		"LOG_MAX_BACKUPS":  &cfg.MaxBackups,
+		"LOG_MAX_AGE_DAYS": &cfg.MaxAgeDays,
+	} {
+		if raw := os.Getenv(env); raw != "" {
+			_, err := fmt.Sscan(raw, value)
+			if err != nil {
+				return cfg, fmt.Errorf("%s: %w", env, err)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+var logMu sync.Mutex
+var logCfg = LogConfig{Level: slog.LevelInfo}
+var logFiles = map[string]*lumberjack.Logger{}
+var loggers = map[LogType]*slog.Logger{}
+
+// SetupLogging configures logging once at startup. Until it is called,
+// every component logs at info level to stdout only.
+func SetupLogging(cfg LogConfig) {
+	logMu.Lock()
+	defer logMu.Unlock()
+
 	for _, file := range logFiles {
-		logger.CreateFileHandler(file)
+		file.Close()
+	}
+
+	logCfg = cfg
+	logFiles = map[string]*lumberjack.Logger{}
+	loggers = map[LogType]*slog.Logger{}
+}
+
+// logFile returns the rotating file of a log, opening it the first time. It's nil without a log directory.
+func logFile(name string) io.Writer {
+	if logCfg.Dir == "" {
+		return nil
+	}
+
+	file, found := logFiles[name]
+	if !found {
+		file = &lumberjack.Logger{
+			Filename:   filepath.Join(logCfg.Dir, name+".log"),
+			MaxSize:    logCfg.MaxSizeMB,
+			MaxBackups: logCfg.MaxBackups,
+			MaxAge:     logCfg.MaxAgeDays,
+		}
+
+		logFiles[name] = file
+	}
+
+	return file
+}
+
+// Log returns the structured logger of a component, e.g.
+//
+//	utils.Log(utils.Crawler).Info("Crawled", "source", url.Source, "url", url.URL)
+func Log(component LogType) *slog.Logger {
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	if logger, found := loggers[component]; found {
+		return logger
+	}
+
+	level, found := logCfg.Levels[component]
+	if !found {
+		level = logCfg.Level
+	}
+
+	out := io.Writer(os.Stdout)
+	if file := logFile(component); file != nil {
+		out = io.MultiWriter(os.Stdout, file)
+	}
+
+	handlers := fanoutHandler{slog.NewJSONHandler(out, &slog.HandlerOptions{Level: level})}
+
+	if file := logFile(Error); file != nil && component != Error {
+		handlers = append(handlers, slog.NewJSONHandler(file, &slog.HandlerOptions{Level: slog.LevelError}))
+	}
+
+	logger := slog.New(handlers).With("component", component)
+
+	loggers[component] = logger
+
+	return logger
+}
+
+// Logger logs a message assembled from stmts.
+//
+// Deprecated: use Log, which takes structured fields. Logger is kept for the
+// site packages; logs of type Error are logged at error level.
+func Logger(logType LogType, scope string, stmts ...interface{}) {
+	if logType == Error {
+		Log(Error).Error(fmt.Sprint(stmts...), "scope", scope)
+		return
+	}
+
+	Log(logType).Info(fmt.Sprint(stmts...), "scope", scope)
+}
+
+// fanoutHandler hands every record to each of its handlers that wants it.
+type fanoutHandler []slog.Handler
+
+func (handlers fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range handlers {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (handlers fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	for _, h := range handlers {
+		if !h.Enabled(ctx, record.Level) {
+			continue
+		}
+
+		err := h.Handle(ctx, record.Clone())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (handlers fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	with := fanoutHandler{}
+
+	for _, h := range handlers {
+		with = append(with, h.WithAttrs(attrs))
+	}
+
+	return with
+}
+
+func (handlers fanoutHandler) WithGroup(name string) slog.Handler {
+	with := fanoutHandler{}
+
+	for _, h := range handlers {
+		with = append(with, h.WithGroup(name))
 	}
 
-	logger.Log(logType, scope, stmts...)
+	return with
 }
@@ -0,0 +1,355 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Cedi-Search/Cedi-Search-Engine/config"
+	"github.com/Cedi-Search/Cedi-Search-Engine/data"
+	"github.com/Cedi-Search/Cedi-Search-Engine/metrics"
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+)
+
+// ErrNoProxy is returned when every proxy of a host's pool is benched.
+var ErrNoProxy = errors.New("every proxy in the pool is benched")
+
+// Proxy is an HTTP or SOCKS5 egress, e.g. socks5://10.0.0.2:1080.
+//
+// A proxy that gets blocked is benched: left out of its pools for
+// config.PROXY_BENCH_TIME, doubling with every block in a row. After that it
+// stays out until a probe of the page it was blocked on gets through, see
+// CheckBenchedProxies.
+type Proxy struct {
+	URL *url.URL
+
+	client      *http.Client
+	browser     *rod.Browser
+	browserOnce sync.Once
+
+	mu           sync.Mutex
+	strikes      int
+	benchedUntil time.Time
+	// probeURL is the page the proxy was blocked on. It's set until a probe of it gets through.
+	probeURL string
+	stats    data.ProxyStats
+}
+
+// ProxyPool hands out the healthy proxies of a pool in turn.
+type ProxyPool struct {
+	proxies []*Proxy
+	next    atomic.Uint64
+}
+
+var proxiesMu sync.Mutex
+
+// proxies holds every proxy by its URL so pools sharing a proxy share its health and stats.
+var proxies = map[string]*Proxy{}
+
+// proxyPools maps hosts to the pool assigned to their target.
+var proxyPools = map[string]*ProxyPool{}
+
+var defaultPool *ProxyPool
+var defaultPoolOnce sync.Once
+
+// getProxy parses a proxy URL, reusing the proxy if it's already known.
+func getProxy(raw string) (*Proxy, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("proxy %s: unsupported scheme %q", u.Redacted(), u.Scheme)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy %s: missing host", u.Redacted())
+	}
+
+	proxiesMu.Lock()
+	defer proxiesMu.Unlock()
+
+	if proxy, found := proxies[u.String()]; found {
+		return proxy, nil
+	}
+
+	proxy := &Proxy{
+		URL: u,
+		client: &http.Client{
+			Timeout:   httpClient.Timeout,
+			Transport: &http.Transport{Proxy: http.ProxyURL(u)},
+		},
+		stats: data.ProxyStats{Proxy: u.Redacted()},
+	}
+
+	proxies[u.String()] = proxy
+
+	return proxy, nil
+}
+
+// NewProxyPool builds a pool from proxy URLs. Invalid URLs are reported and left out.
+func NewProxyPool(raw []string) (*ProxyPool, []error) {
+	pool := &ProxyPool{}
+	errs := []error{}
+
+	for _, r := range raw {
+		if strings.TrimSpace(r) == "" {
+			continue
+		}
+
+		proxy, err := getProxy(r)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		pool.proxies = append(pool.proxies, proxy)
+	}
+
+	return pool, errs
+}
+
+// AssignProxies makes a host fetch through its own pool instead of the default one.
+// It's called with a target's Proxies; an empty list keeps the default pool.
+func AssignProxies(host string, raw []string) []error {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	pool, errs := NewProxyPool(raw)
+
+	proxiesMu.Lock()
+	proxyPools[strings.ToLower(host)] = pool
+	proxiesMu.Unlock()
+
+	return errs
+}
+
+// getDefaultPool builds the pool of the comma-separated PROXIES
+// environment variable the first time it is needed.
+func getDefaultPool() *ProxyPool {
+	defaultPoolOnce.Do(func() {
+		var errs []error
+
+		defaultPool, errs = NewProxyPool(strings.Split(os.Getenv("PROXIES"), ","))
+
+		for _, err := range errs {
+			Log(Utils).Error("Invalid proxy", "error", err)
+		}
+	})
+
+	return defaultPool
+}
+
+// proxyFor picks the proxy to fetch a URL through, or nil to fetch directly
+// when no pool applies to its host.
+func proxyFor(href string) (*Proxy, error) {
+	host := ""
+
+	if u, err := url.Parse(href); err == nil {
+		host = strings.ToLower(u.Host)
+	}
+
+	proxiesMu.Lock()
+	pool, found := proxyPools[host]
+	proxiesMu.Unlock()
+
+	if !found {
+		pool = getDefaultPool()
+	}
+
+	return pool.Pick()
+}
+
+// Pick returns the next proxy that isn't benched. It returns nil for an empty pool.
+func (pool *ProxyPool) Pick() (*Proxy, error) {
+	if len(pool.proxies) == 0 {
+		return nil, nil
+	}
+
+	start := pool.next.Add(1)
+
+	for i := range pool.proxies {
+		proxy := pool.proxies[(start+uint64(i))%uint64(len(pool.proxies))]
+
+		if !proxy.Benched() {
+			return proxy, nil
+		}
+	}
+
+	return nil, ErrNoProxy
+}
+
+// LogValue logs a proxy as its URL without the password, or "direct" for none.
+func (proxy *Proxy) LogValue() slog.Value {
+	if proxy == nil {
+		return slog.StringValue("direct")
+	}
+
+	return slog.StringValue(proxy.URL.Redacted())
+}
+
+// Benched reports whether the proxy is sitting out after being blocked,
+// including while it waits for a probe to get through.
+func (proxy *Proxy) Benched() bool {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+
+	return proxy.probeURL != "" || time.Now().Before(proxy.benchedUntil)
+}
+
+// bench takes the proxy out of its pools after it was blocked on a page.
+// The caller holds proxy.mu.
+func (proxy *Proxy) bench(href string) time.Duration {
+	proxy.strikes++
+
+	bench := config.PROXY_BENCH_TIME << min(proxy.strikes-1, 6)
+	if bench > config.PROXY_MAX_BENCH_TIME {
+		bench = config.PROXY_MAX_BENCH_TIME
+	}
+
+	proxy.benchedUntil = time.Now().Add(bench)
+	proxy.probeURL = href
+
+	return bench
+}
+
+// probe fetches the page the proxy was blocked on once its bench is over,
+// putting the proxy back into rotation if it gets through and benching it
+// again if it doesn't.
+func (proxy *Proxy) probe() {
+	proxy.mu.Lock()
+	href := proxy.probeURL
+	due := href != "" && !time.Now().Before(proxy.benchedUntil)
+	proxy.mu.Unlock()
+
+	if !due {
+		return
+	}
+
+	resp, err := get(proxy.client, href, http.Header{})
+
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+
+	if err != nil || IsBlocked(resp) {
+		bench := proxy.bench(href)
+
+		Log(Utils).Warn("Proxy is still blocked, benching it again", "proxy", proxy, "bench", bench, "url", href, "error", err)
+
+		return
+	}
+
+	proxy.probeURL = ""
+
+	Log(Utils).Info("Proxy got through, putting it back into rotation", "proxy", proxy, "url", href)
+}
+
+// CheckBenchedProxies probes every proxy whose bench is over.
+func CheckBenchedProxies() {
+	proxiesMu.Lock()
+
+	all := make([]*Proxy, 0, len(proxies))
+	for _, proxy := range proxies {
+		all = append(all, proxy)
+	}
+
+	proxiesMu.Unlock()
+
+	for _, proxy := range all {
+		proxy.probe()
+	}
+}
+
+// getBrowser launches a headless browser going through the proxy the first time it is needed.
+//
+// Chromium can't take proxy credentials on the command line, so browsers
+// need proxies that authorise by IP; credentials only work with "soup", and
+// targets.Validate rejects them for targets fetched with the browser.
+// Chromium resolves host names through SOCKS5 proxies itself, so socks5h
+// proxies are handed to it as socks5.
+func (proxy *Proxy) getBrowser() *rod.Browser {
+	proxy.browserOnce.Do(func() {
+		if proxy.URL.User != nil {
+			Log(Utils).Warn("Proxy credentials are ignored by the browser", "proxy", proxy)
+		}
+
+		scheme := proxy.URL.Scheme
+		if scheme == "socks5h" {
+			scheme = "socks5"
+		}
+
+		controlUrl := launcher.New().Headless(true).Proxy(scheme + "://" + proxy.URL.Host).MustLaunch()
+
+		metrics.Browsers.Inc()
+
+		proxy.browser = rod.New().ControlURL(controlUrl).MustConnect().WithPanic(func(i interface{}) {
+			Log(Utils).Error("Headless browser probably lost context", "proxy", proxy)
+		})
+	})
+
+	return proxy.browser
+}
+
+// report records how a fetch through the proxy went and benches it when it got blocked.
+func (proxy *Proxy) report(resp Response, err error) {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+
+	proxy.stats.Requests++
+
+	outcome := "success"
+
+	switch {
+	case err != nil:
+		proxy.stats.Failures++
+		outcome = "failure"
+
+	case IsBlocked(resp):
+		proxy.stats.Blocks++
+		outcome = "blocked"
+
+		bench := proxy.bench(resp.URL)
+
+		Log(Utils).Warn("Benching proxy", "proxy", proxy, "bench", bench, "url", resp.URL)
+
+	default:
+		proxy.stats.Successes++
+		proxy.strikes = 0
+	}
+
+	metrics.ProxyRequests.WithLabelValues(proxy.stats.Proxy, outcome).Inc()
+}
+
+// TakeProxyStats returns the counts of every proxy used since the last call and resets them.
+func TakeProxyStats() []data.ProxyStats {
+	proxiesMu.Lock()
+	defer proxiesMu.Unlock()
+
+	stats := []data.ProxyStats{}
+
+	for _, proxy := range proxies {
+		proxy.mu.Lock()
+
+		if proxy.stats.Requests > 0 {
+			stats = append(stats, proxy.stats)
+		}
+
+		proxy.stats = data.ProxyStats{Proxy: proxy.stats.Proxy}
+
+		proxy.mu.Unlock()
+	}
+
+	return stats
+}
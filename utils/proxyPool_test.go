@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBenchedProxyIsProbedBackIntoRotation(t *testing.T) {
+	blocked := atomic.Bool{}
+	blocked.Store(true)
+
+	// Plain HTTP requests through a proxy are sent to it with the full URL,
+	// so a test server can stand in for the proxy and the shop at once.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if blocked.Load() {
+			http.Error(w, "Access denied", http.StatusForbidden)
+			return
+		}
+
+		// Bigger than config.BLOCK_MIN_BODY, so it isn't taken for an empty shell.
+		w.Write([]byte("<html><body>" + strings.Repeat("<p>A product page</p>", 200) + "</body></html>"))
+	}))
+	defer server.Close()
+
+	pool, errs := NewProxyPool([]string{server.URL})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	proxy := pool.proxies[0]
+	href := "http://www.jumia.com.gh/tecno-spark-10-pro-48823011.html"
+
+	proxy.report(Response{URL: href, Status: http.StatusForbidden}, nil)
+
+	if !proxy.Benched() {
+		t.Fatal("a blocked proxy isn't benched")
+	}
+
+	endBench := func() {
+		proxy.mu.Lock()
+		proxy.benchedUntil = time.Now().Add(-time.Second)
+		proxy.mu.Unlock()
+	}
+
+	endBench()
+
+	if !proxy.Benched() {
+		t.Fatal("a proxy is back in rotation before a probe got through")
+	}
+
+	proxy.probe()
+
+	if !proxy.Benched() {
+		t.Fatal("a proxy is back in rotation after a blocked probe")
+	}
+
+	if proxy.strikes != 2 {
+		t.Errorf("strikes = %d after a blocked probe, want 2", proxy.strikes)
+	}
+
+	blocked.Store(false)
+
+	// The bench after the failed probe isn't over yet.
+	proxy.probe()
+
+	if !proxy.Benched() {
+		t.Fatal("a proxy was probed before its bench was over")
+	}
+
+	endBench()
+	proxy.probe()
+
+	if proxy.Benched() {
+		t.Fatal("a proxy isn't back in rotation after a probe got through")
+	}
+
+	picked, err := pool.Pick()
+	if err != nil || picked != proxy {
+		t.Errorf("Pick() = %v, %v; want the proxy", picked, err)
+	}
+}
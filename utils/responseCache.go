@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotCached is returned by fetches in offline mode when a page isn't in the response cache.
+var ErrNotCached = errors.New("page is not in the response cache")
+
+// ResponseCache is an on-disk cache of fetched pages, keyed by fetcher and URL,
+// meant for development: tuning selectors without hitting the shops every time.
+//
+// Entries live in <dir>/<host>/<hash>.json so a whole shop can be purged at once.
+type ResponseCache struct {
+	Dir     string
+	TTL     time.Duration
+	Offline bool
+}
+
+var responseCache *ResponseCache
+var responseCacheOnce sync.Once
+
+// GetResponseCache returns the response cache configured by the environment,
+// or nil when caching is off. It is read on first use, after .env is loaded.
+//
+// FETCH_CACHE_DIR turns the cache on, FETCH_CACHE_TTL (a Go duration, default 24h)
+// sets how long entries stay fresh and FETCH_CACHE_OFFLINE=1 serves only from the
+// cache, ignoring the TTL and never touching the network.
+func GetResponseCache() *ResponseCache {
+	responseCacheOnce.Do(func() {
+		dir := os.Getenv("FETCH_CACHE_DIR")
+		if dir == "" {
+			return
+		}
+
+		ttl, err := time.ParseDuration(os.Getenv("FETCH_CACHE_TTL"))
+		if err != nil {
+			ttl = 24 * time.Hour
+		}
+
+		responseCache = &ResponseCache{
+			Dir:     dir,
+			TTL:     ttl,
+			Offline: os.Getenv("FETCH_CACHE_OFFLINE") == "1",
+		}
+
+		Log(Utils).Info("Response cache enabled", "dir", dir, "ttl", ttl, "offline", responseCache.Offline)
+	})
+
+	return responseCache
+}
+
+// entryPath returns where the entry for a fetcher and URL is stored.
+func (cache *ResponseCache) entryPath(fetcher, href string) string {
+	host := "unknown"
+
+	if u, err := url.Parse(href); err == nil && u.Host != "" {
+		host = strings.ToLower(u.Host)
+	}
+
+	sum := sha256.Sum256([]byte(fetcher + " " + href))
+
+	return filepath.Join(cache.Dir, host, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get returns the cached response for a fetcher and URL, if there is a fresh one.
+// In offline mode every cached response counts as fresh.
+func (cache *ResponseCache) Get(fetcher, href string) (Response, bool) {
+	resp := Response{}
+
+	content, err := os.ReadFile(cache.entryPath(fetcher, href))
+	if err != nil {
+		return resp, false
+	}
+
+	err = json.Unmarshal(content, &resp)
+	if err != nil {
+		return resp, false
+	}
+
+	if !cache.Offline && time.Since(resp.FetchedAt) > cache.TTL {
+		return resp, false
+	}
+
+	return resp, true
+}
+
+// Put stores a response in the cache.
+func (cache *ResponseCache) Put(resp Response) error {
+	file := cache.entryPath(resp.Fetcher, resp.URL)
+
+	err := os.MkdirAll(filepath.Dir(file), 0o755)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, content, 0o644)
+}
+
+var hostRe = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?(:[0-9]+)?$`)
+
+// PurgeHost deletes every cached response of a host, e.g. www.jumia.com.gh.
+// Anything that isn't a host name, like a path, is rejected.
+func (cache *ResponseCache) PurgeHost(host string) error {
+	host = strings.ToLower(host)
+
+	if !hostRe.MatchString(host) || strings.Contains(host, "..") {
+		return fmt.Errorf("%q is not a host name", host)
+	}
+
+	return os.RemoveAll(filepath.Join(cache.Dir, host))
+}
+
+// Purge deletes every cached response.
+func (cache *ResponseCache) Purge() error {
+	return os.RemoveAll(cache.Dir)
+}
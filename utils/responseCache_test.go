@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPurgeHostRejectsPaths(t *testing.T) {
+	root := t.TempDir()
+	cache := &ResponseCache{Dir: filepath.Join(root, "cache")}
+
+	for _, dir := range []string{"cache/www.jumia.com.gh", "cache/deus.com.gh", "outside"} {
+		err := os.MkdirAll(filepath.Join(root, dir), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, host := range []string{"..", "../outside", "..\\outside", "a/../../outside", "/", "", "www..jumia.com.gh"} {
+		if err := cache.PurgeHost(host); err == nil {
+			t.Errorf("PurgeHost(%q) succeeded, want an error", host)
+		}
+	}
+
+	err := cache.PurgeHost("WWW.Jumia.com.gh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for dir, want := range map[string]bool{"cache/www.jumia.com.gh": false, "cache/deus.com.gh": true, "outside": true} {
+		_, err := os.Stat(filepath.Join(root, dir))
+		if exists := err == nil; exists != want {
+			t.Errorf("%s exists = %v, want %v", dir, exists, want)
+		}
+	}
+}